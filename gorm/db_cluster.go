@@ -0,0 +1,359 @@
+package gorm
+
+import (
+	"context"
+	"database/sql"
+	g "gorm.io/gorm"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const defaultReplicaCheckInterval = 10 * time.Second
+const usePrimarySetting = "gwuu:use_primary"
+const replicaPoolSetting = "gwuu:replica_pool"
+
+// ReplicaPolicy is a way of choosing replica for read query
+type ReplicaPolicy int
+
+const (
+	// RoundRobin chooses healthy replicas one by one
+	RoundRobin ReplicaPolicy = iota
+	// LeastConnections chooses healthy replica with minimal number of connections in use
+	LeastConnections
+)
+
+// ClusterOptions is an options of OpenCluster
+type ClusterOptions struct {
+	// Policy is a replica choosing policy, RoundRobin by default
+	Policy ReplicaPolicy
+	// CheckInterval is an interval of replicas health checks, 10s if not set, negative value disables periodic checks
+	CheckInterval time.Duration
+	// CheckTimeout is a timeout of replica health check query, 5s if not set
+	CheckTimeout time.Duration
+	// Open are connection pool limits and session settings of primary and replicas, retry policy is applied only to
+	// primary (could be nil)
+	Open *OpenOptions
+}
+
+// primaryContextKey is a key of context value that forces primary usage (see ContextWithPrimary)
+type primaryContextKey struct{}
+
+// clusterReplica is a replica connection pool with health state
+type clusterReplica struct {
+	db      *sql.DB
+	healthy atomic.Bool
+}
+
+// Cluster is a primary database with read replicas that are opened as single gorm.DB (see DB): queries of Find, First,
+// Count, Pluck, Scan, Row(s) and Raw SELECT are sent to healthy replica, queries of Create, Update, Delete, Exec,
+// locking reads (FOR UPDATE / FOR SHARE) and any query inside transaction are sent to primary. If all replicas are
+// unhealthy reads are sent to primary. Replicas are checked periodically and replica is taken out of rotation
+// immediately if query on it failed with connection error (see IsConnectionError)
+type Cluster struct {
+	dialect       SqlDialect
+	db            *g.DB
+	primary       g.ConnPool
+	replicas      []*clusterReplica
+	policy        ReplicaPolicy
+	checkInterval time.Duration
+	checkTimeout  time.Duration
+	next          atomic.Uint64
+	stop          chan struct{}
+	wg            sync.WaitGroup
+}
+
+// OpenCluster
+/* Function that opens primary database and replicas as a cluster, replicas that are not available at opening are
+ * marked unhealthy and returned to rotation when they pass health check. Databases are neither checked nor created
+ * Parameters:
+ *    - ctx - context that bounds opening of primary and first replicas health check
+ *    - dialect - string that represent using db driver inside gorm (see enum above)
+ *    - primaryConnStr - full connection string of primary database
+ *    - replicaConnStrs - full connection strings of replica databases (could be empty)
+ *    - options - gorm config
+ *    - clusterOptions - replica policy, health checks and open options (could be nil)
+ * Returns tuple of cluster and error (DbError with ErrOpenFailed kind)
+ */
+func OpenCluster(ctx context.Context, dialect SqlDialect, primaryConnStr string, replicaConnStrs []string,
+	options *g.Config, clusterOptions *ClusterOptions) (*Cluster, error) {
+	if options == nil {
+		options = &g.Config{}
+	}
+	cluster := Cluster{dialect: dialect, checkInterval: defaultReplicaCheckInterval,
+		checkTimeout: defaultHealthCheckTimeout, stop: make(chan struct{})}
+	var openOptions *OpenOptions
+	if clusterOptions != nil {
+		cluster.policy = clusterOptions.Policy
+		if clusterOptions.CheckInterval != 0 {
+			cluster.checkInterval = clusterOptions.CheckInterval
+		}
+		if clusterOptions.CheckTimeout > 0 {
+			cluster.checkTimeout = clusterOptions.CheckTimeout
+		}
+		openOptions = clusterOptions.Open
+	}
+	db, err := OpenDb2Context(ctx, dialect, primaryConnStr, false, false, options, nil, openOptions)
+	if err != nil {
+		return nil, err
+	}
+	cluster.db = db
+	cluster.primary = db.ConnPool
+	var replicaOpenOptions *OpenOptions
+	if openOptions != nil {
+		replicaOpenOptions = &OpenOptions{Pool: openOptions.Pool, Session: openOptions.Session}
+	}
+	for _, connStr := range replicaConnStrs {
+		sqlDb, _, openErr := openSqlDb(dialect, connStr, replicaOpenOptions)
+		if openErr != nil {
+			_ = cluster.Close()
+			return nil, newDbErrorContext(ctx, ErrOpenFailed, dialect, "", openErr)
+		}
+		cluster.replicas = append(cluster.replicas, &clusterReplica{db: sqlDb})
+	}
+	cluster.CheckReplicas(ctx)
+
+	err = cluster.registerCallbacks()
+	if err != nil {
+		_ = cluster.Close()
+		return nil, newDbErrorContext(ctx, ErrOpenFailed, dialect, "", err)
+	}
+	if cluster.checkInterval > 0 && len(cluster.replicas) > 0 {
+		cluster.wg.Add(1)
+		go cluster.checkReplicasPeriodically()
+	}
+	return &cluster, nil
+}
+
+// DB
+/* Function that returns gorm database of cluster, it's ConnPool (and DB()) is a primary connection pool
+ * Returns gorm.DB address of database context object
+ */
+func (c *Cluster) DB() *g.DB {
+	return c.db
+}
+
+// HealthyReplicas
+/* Function that returns number of replicas that are in rotation
+ * Returns number of healthy replicas
+ */
+func (c *Cluster) HealthyReplicas() int {
+	healthy := 0
+	for _, replica := range c.replicas {
+		if replica.healthy.Load() {
+			healthy++
+		}
+	}
+	return healthy
+}
+
+// CheckReplicas
+/* Function that runs health check query (see HealthCheck) on every replica, replicas that passed check are returned
+ * to rotation and failed replicas are taken out of it. It is called periodically with CheckInterval
+ * Parameters:
+ *    - ctx - context that bounds checks
+ * Returns number of healthy replicas
+ */
+func (c *Cluster) CheckReplicas(ctx context.Context) int {
+	query := getVersionQuery(c.dialect)
+	var wg sync.WaitGroup
+	for _, replica := range c.replicas {
+		wg.Add(1)
+		go func(replica *clusterReplica) {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, c.checkTimeout)
+			defer cancel()
+			var version string
+			err := replica.db.QueryRowContext(checkCtx, query).Scan(&version)
+			replica.healthy.Store(err == nil)
+		}(replica)
+	}
+	wg.Wait()
+	return c.HealthyReplicas()
+}
+
+// Close
+/* Function that stops health checks and closes replicas and primary connection pools
+ * Returns error of first failed close (DbError with ErrCloseFailed kind)
+ */
+func (c *Cluster) Close() error {
+	select {
+	case <-c.stop:
+		return nil
+	default:
+		close(c.stop)
+	}
+	c.wg.Wait()
+	var closeErr error
+	for _, replica := range c.replicas {
+		err := replica.db.Close()
+		if err != nil && closeErr == nil {
+			closeErr = newDbError(ErrCloseFailed, c.dialect, "", err)
+		}
+	}
+	if c.db != nil {
+		sqlDb, err := c.db.DB()
+		if err == nil {
+			err = sqlDb.Close()
+		}
+		if err != nil && closeErr == nil {
+			closeErr = newDbError(ErrCloseFailed, c.dialect, "", err)
+		}
+	}
+	return closeErr
+}
+
+// UsePrimary
+/* Function that forces reading from primary (i.e. to read just written data), it could be used with any gorm.DB
+ * Parameters:
+ *    - db - cluster database (or any other gorm database)
+ * Returns gorm.DB address of database context object which queries are sent to primary
+ */
+func UsePrimary(db *g.DB) *g.DB {
+	return db.Set(usePrimarySetting, true)
+}
+
+// ContextWithPrimary
+/* Function that creates context that forces reading from primary in all queries that are bound to it (db.WithContext),
+ * i.e. for the rest of request handling after write
+ * Parameters:
+ *    - ctx - parent context
+ * Returns context
+ */
+func ContextWithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryContextKey{}, true)
+}
+
+// registerCallbacks
+/* Function that registers callbacks that switch query and row statements to replica and back
+ */
+func (c *Cluster) registerCallbacks() error {
+	err := c.db.Callback().Query().Before("gorm:query").Register("gwuu:use_replica", c.useReplica)
+	if err != nil {
+		return err
+	}
+	err = c.db.Callback().Query().After("gorm:query").Register("gwuu:release_replica", c.releaseReplica)
+	if err != nil {
+		return err
+	}
+	err = c.db.Callback().Row().Before("gorm:row").Register("gwuu:use_replica", c.useReplica)
+	if err != nil {
+		return err
+	}
+	return c.db.Callback().Row().After("gorm:row").Register("gwuu:release_replica", c.releaseReplica)
+}
+
+// useReplica
+/* Function (callback) that replaces statement connection pool with chosen replica if statement is a read outside of
+ * transaction and primary is not forced, original pool is restored by releaseReplica
+ */
+func (c *Cluster) useReplica(db *g.DB) {
+	stmt := db.Statement
+	if db.Error != nil || stmt.ConnPool != c.primary {
+		// transaction (or prepared statements) pool
+		return
+	}
+	if forced, ok := stmt.Settings.Load(usePrimarySetting); ok && forced == true {
+		return
+	}
+	if stmt.Context != nil && stmt.Context.Value(primaryContextKey{}) != nil {
+		return
+	}
+	if _, locking := stmt.Clauses["FOR"]; locking {
+		return
+	}
+	if stmt.SQL.Len() > 0 && !isReadQuery(stmt.SQL.String()) {
+		return
+	}
+	replica := c.chooseReplica()
+	if replica == nil {
+		return
+	}
+	stmt.Settings.Store(replicaPoolSetting, replica)
+	stmt.ConnPool = replica.db
+}
+
+// releaseReplica
+/* Function (callback) that restores primary connection pool of statement and takes replica out of rotation if query
+ * failed with connection error
+ */
+func (c *Cluster) releaseReplica(db *g.DB) {
+	value, ok := db.Statement.Settings.LoadAndDelete(replicaPoolSetting)
+	if !ok {
+		return
+	}
+	db.Statement.ConnPool = c.primary
+	if IsConnectionError(db.Error) {
+		value.(*clusterReplica).healthy.Store(false)
+	}
+}
+
+// chooseReplica
+/* Function that chooses healthy replica according to policy
+ * Returns replica or nil if there are no healthy replicas
+ */
+func (c *Cluster) chooseReplica() *clusterReplica {
+	count := len(c.replicas)
+	if count == 0 {
+		return nil
+	}
+	if c.policy == LeastConnections {
+		var chosen *clusterReplica
+		inUse := 0
+		for _, replica := range c.replicas {
+			if !replica.healthy.Load() {
+				continue
+			}
+			replicaInUse := replica.db.Stats().InUse
+			if chosen == nil || replicaInUse < inUse {
+				chosen = replica
+				inUse = replicaInUse
+			}
+		}
+		return chosen
+	}
+	start := c.next.Add(1) - 1
+	for i := 0; i < count; i++ {
+		replica := c.replicas[(start+uint64(i))%uint64(count)]
+		if replica.healthy.Load() {
+			return replica
+		}
+	}
+	return nil
+}
+
+// checkReplicasPeriodically
+/* Function that checks replicas with CheckInterval until cluster is closed
+ */
+func (c *Cluster) checkReplicasPeriodically() {
+	defer c.wg.Done()
+	ticker := time.NewTicker(c.checkInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			c.CheckReplicas(context.Background())
+		}
+	}
+}
+
+// isReadQuery
+/* Function that checks whether raw query could be sent to replica: it is a SELECT without row locks, sequence
+ * functions and SELECT INTO
+ */
+func isReadQuery(query string) bool {
+	normalized := strings.ToUpper(strings.Join(strings.Fields(query), " "))
+	if !strings.HasPrefix(normalized, "SELECT ") {
+		return false
+	}
+	for _, marker := range []string{" FOR UPDATE", " FOR SHARE", " FOR NO KEY UPDATE", " FOR KEY SHARE",
+		" LOCK IN SHARE MODE", " INTO ", "NEXTVAL(", "SETVAL(", "NEXT VALUE FOR "} {
+		if strings.Contains(normalized, marker) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,106 @@
+package gorm
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"path/filepath"
+	"testing"
+)
+
+// Node is a model which rows are different in primary and replica databases of cluster tests
+type Node struct {
+	ID   uint `gorm:"primaryKey"`
+	Name string
+}
+
+func TestSqliteClusterReadWriteSplitting(t *testing.T) {
+	ctx := context.Background()
+	primaryPath, replicaPath := prepareClusterDatabases(t)
+	cluster, err := OpenCluster(ctx, Sqlite, primaryPath, []string{replicaPath}, &gorm.Config{},
+		&ClusterOptions{CheckInterval: -1})
+	assert.NoError(t, err)
+	defer cluster.Close()
+	db := cluster.DB()
+	assert.Equal(t, 1, cluster.HealthyReplicas())
+
+	var node Node
+	assert.NoError(t, db.First(&node).Error)
+	assert.Equal(t, "replica", node.Name)
+	var name string
+	assert.NoError(t, db.Raw("SELECT name FROM nodes WHERE id = ?", 1).Scan(&name).Error)
+	assert.Equal(t, "replica", name)
+
+	// writes, forced primary reads and transactions use primary
+	assert.NoError(t, db.Create(&Node{Name: "created"}).Error)
+	var count int64
+	assert.NoError(t, db.Model(&Node{}).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+	assert.NoError(t, UsePrimary(db).Model(&Node{}).Count(&count).Error)
+	assert.Equal(t, int64(2), count)
+	assert.NoError(t, db.WithContext(ContextWithPrimary(ctx)).First(&node, 1).Error)
+	assert.Equal(t, "primary", node.Name)
+	assert.NoError(t, db.Transaction(func(tx *gorm.DB) error {
+		return tx.Model(&Node{}).Count(&count).Error
+	}))
+	assert.Equal(t, int64(2), count)
+
+	// chained statement is returned to primary after read
+	query := db.Model(&Node{}).Where("id = ?", 1)
+	assert.NoError(t, query.Count(&count).Error)
+	assert.NoError(t, query.Update("name", "updated").Error)
+	assert.NoError(t, UsePrimary(db).First(&node, 1).Error)
+	assert.Equal(t, "updated", node.Name)
+}
+
+func TestSqliteClusterWithUnhealthyReplica(t *testing.T) {
+	ctx := context.Background()
+	primaryPath, replicaPath := prepareClusterDatabases(t)
+	missingReplicaPath := "file:" + filepath.Join(t.TempDir(), "missing", "replica.db") + "?mode=ro"
+	cluster, err := OpenCluster(ctx, Sqlite, primaryPath, []string{missingReplicaPath, replicaPath}, &gorm.Config{},
+		&ClusterOptions{Policy: LeastConnections, CheckInterval: -1})
+	assert.NoError(t, err)
+	defer cluster.Close()
+	assert.Equal(t, 1, cluster.HealthyReplicas())
+	for i := 0; i < 3; i++ {
+		var node Node
+		assert.NoError(t, cluster.DB().First(&node).Error)
+		assert.Equal(t, "replica", node.Name)
+	}
+
+	// reads are sent to primary if there are no healthy replicas
+	cluster.replicas[1].healthy.Store(false)
+	var node Node
+	assert.NoError(t, cluster.DB().First(&node).Error)
+	assert.Equal(t, "primary", node.Name)
+	assert.Equal(t, 1, cluster.CheckReplicas(ctx))
+	assert.NoError(t, cluster.Close())
+	assert.NoError(t, cluster.Close())
+}
+
+func TestIsReadQuery(t *testing.T) {
+	assert.True(t, isReadQuery("SELECT * FROM users WHERE id = ?"))
+	assert.True(t, isReadQuery("  select\n name from users"))
+	assert.False(t, isReadQuery("SELECT * FROM users WHERE id = ? FOR UPDATE"))
+	assert.False(t, isReadQuery("SELECT * FROM users LOCK IN SHARE MODE"))
+	assert.False(t, isReadQuery("SELECT nextval('users_id_seq')"))
+	assert.False(t, isReadQuery("SELECT * INTO users_copy FROM users"))
+	assert.False(t, isReadQuery("INSERT INTO users (name) VALUES (?) RETURNING id"))
+	assert.False(t, isReadQuery("WITH deleted AS (DELETE FROM users RETURNING id) SELECT count(*) FROM deleted"))
+}
+
+// prepareClusterDatabases
+/* Function that creates primary and replica Sqlite databases with nodes table, node with id 1 is named as database
+ */
+func prepareClusterDatabases(t *testing.T) (string, string) {
+	dir := t.TempDir()
+	paths := []string{filepath.Join(dir, "primary.db"), filepath.Join(dir, "replica.db")}
+	for i, name := range []string{"primary", "replica"} {
+		db, err := OpenDb2WithError(Sqlite, paths[i], false, false, &gorm.Config{}, nil)
+		assert.NoError(t, err)
+		assert.NoError(t, db.AutoMigrate(&Node{}))
+		assert.NoError(t, db.Create(&Node{Name: name}).Error)
+		CloseDb(db)
+	}
+	return paths[0], paths[1]
+}
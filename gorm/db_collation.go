@@ -0,0 +1,253 @@
+package gorm
+
+import (
+	"context"
+	"errors"
+	g "gorm.io/gorm"
+	"sort"
+	"strings"
+)
+
+// Collation parameters names that are returned by GetDatabaseCollation
+const (
+	PostgresLcCollateParam = "LC_COLLATE"
+	PostgresLcCtypeParam   = "LC_CTYPE"
+	MysqlCollateParam      = "COLLATE"
+)
+
+// CollationMismatch is a difference between desired and actual database collation option
+type CollationMismatch struct {
+	// Option is Encoding or Parameters key (i.e. LC_COLLATE)
+	Option   string
+	Expected string
+	Actual   string
+}
+
+func (m CollationMismatch) String() string {
+	return m.Option + ": expected \"" + m.Expected + "\", actual \"" + m.Actual + "\""
+}
+
+// CollationMismatchError is an error that is returned when existing database collation differs from desired one, it
+// matches ErrCollationMismatch (errors.Is)
+type CollationMismatchError struct {
+	Mismatches []CollationMismatch
+}
+
+func (e *CollationMismatchError) Error() string {
+	mismatches := make([]string, len(e.Mismatches))
+	for i, m := range e.Mismatches {
+		mismatches[i] = m.String()
+	}
+	return ErrCollationMismatch.Error() + ": " + strings.Join(mismatches, ", ")
+}
+
+func (e *CollationMismatchError) Is(target error) bool {
+	return target == ErrCollationMismatch
+}
+
+// collationRow is a result of collation catalog query, CtypeName is used only by Postgres
+type collationRow struct {
+	EncodingName  string
+	CollationName string
+	CtypeName     string
+}
+
+// GetDatabaseCollation
+/* Function that reads collation of existing database from server catalog through system database:
+ *    - Postgres - Encoding is database encoding, Parameters contain LC_COLLATE and LC_CTYPE (pg_database)
+ *    - Mysql - Encoding is default character set, Parameters contain COLLATE (information_schema.SCHEMATA)
+ *    - Mssql - Encoding is database collation, Parameters are empty (sys.databases)
+ * Sqlite is not supported (it has no database collation)
+ * Parameters:
+ *    - dialect - string that represent using db driver inside gorm (see enum above)
+ *    - systemDbConnStr - connection string to system database (see createSystemDbConnStr)
+ *    - dbName - database name
+ * Returns tuple of collation and error (DbError), if database does not exist error matches ErrDatabaseNotFound
+ */
+func GetDatabaseCollation(dialect SqlDialect, systemDbConnStr string, dbName string) (*Collation, error) {
+	return GetDatabaseCollationContext(context.Background(), dialect, systemDbConnStr, dbName, &g.Config{})
+}
+
+// GetDatabaseCollationContext
+/* Function that does same as GetDatabaseCollation but connection to server and catalog query could be cancelled or
+ * bounded by ctx deadline
+ * Parameters:
+ *    - ctx - context that bounds reading
+ *    - options - gorm config (from gorm.io/gorm NOT from github.com/jinzhu/gorm)
+ *    - other parameters are the same as in GetDatabaseCollation
+ * Returns tuple of collation and error (DbError)
+ */
+func GetDatabaseCollationContext(ctx context.Context, dialect SqlDialect, systemDbConnStr string, dbName string,
+	options *g.Config) (*Collation, error) {
+	query := getDatabaseCollationQuery(dialect)
+	if len(query) == 0 {
+		return nil, newDbError(ErrOpenFailed, dialect, dbName, ErrUnsupportedDialect)
+	}
+	systemDb, err := openDb(ctx, dialect, systemDbConnStr, options, nil)
+	if err != nil {
+		return nil, newDbErrorContext(ctx, ErrOpenFailed, dialect, dbName, errors.Unwrap(err))
+	}
+	defer CloseDb(systemDb)
+	return queryDatabaseCollation(ctx, dialect, systemDb, dbName)
+}
+
+// queryDatabaseCollation
+/* Function that reads collation of existing database from server catalog through opened database (catalog is
+ * available from any database of server)
+ * Parameters:
+ *    - ctx - context that bounds query
+ *    - dialect - string that represent using db driver inside gorm (see enum above)
+ *    - catalogDb - opened system (or any other) database
+ *    - dbName - database name
+ * Returns tuple of collation and error (DbError)
+ */
+func queryDatabaseCollation(ctx context.Context, dialect SqlDialect, catalogDb *g.DB, dbName string) (*Collation,
+	error) {
+	var rows []collationRow
+	err := catalogDb.WithContext(ctx).Raw(getDatabaseCollationQuery(dialect), dbName).Scan(&rows).Error
+	if err != nil {
+		return nil, newDbErrorContext(ctx, ErrOpenFailed, dialect, dbName, err)
+	}
+	if len(rows) == 0 {
+		return nil, newDbError(ErrOpenFailed, dialect, dbName, ErrDatabaseNotFound)
+	}
+	row := rows[0]
+	collation := Collation{Encoding: row.EncodingName, Parameters: map[string]string{}}
+	switch dialect {
+	case Postgres:
+		collation.Parameters[PostgresLcCollateParam] = row.CollationName
+		collation.Parameters[PostgresLcCtypeParam] = row.CtypeName
+	case Mysql:
+		collation.Parameters[MysqlCollateParam] = row.CollationName
+	}
+	return &collation, nil
+}
+
+// CompareCollation
+/* Function that compares desired collation (that was passed to OpenDb / CreateRandomDb) with actual one (returned by
+ * GetDatabaseCollation). Encoding and only those expected Parameters that are present in actual collation are compared
+ * (i.e. Postgres TEMPLATE is a creation option, not a database property), values are compared case-insensitive and
+ * without dashes (UTF8 equals utf-8), locale names are normalized like glibc does (en_US.UTF-8 equals en_US.utf8,
+ * POSIX equals C)
+ * Parameters:
+ *    - expected - desired collation, if nil or Encoding is empty there is nothing to compare
+ *    - actual - database collation
+ * Returns mismatches sorted by option name (empty slice if collations match)
+ */
+func CompareCollation(expected *Collation, actual *Collation) []CollationMismatch {
+	mismatches := make([]CollationMismatch, 0)
+	if expected == nil || len(expected.Encoding) == 0 {
+		return mismatches
+	}
+	if actual == nil {
+		actual = &Collation{}
+	}
+	if !isSameCollationValue(expected.Encoding, actual.Encoding) {
+		mismatches = append(mismatches, CollationMismatch{Option: "Encoding", Expected: expected.Encoding,
+			Actual: actual.Encoding})
+	}
+	for key, expectedValue := range expected.Parameters {
+		for actualKey, actualValue := range actual.Parameters {
+			if strings.EqualFold(key, actualKey) && !isSameCollationValue(expectedValue, actualValue) {
+				mismatches = append(mismatches, CollationMismatch{Option: actualKey, Expected: expectedValue,
+					Actual: actualValue})
+			}
+		}
+	}
+	sort.Slice(mismatches, func(i, j int) bool {
+		return mismatches[i].Option < mismatches[j].Option
+	})
+	return mismatches
+}
+
+// verifyDatabaseCollation
+/* Function that reads existing database collation and compares it with desired one
+ * Parameters:
+ *    - ctx - context that bounds reading
+ *    - dialect - string that represent using db driver inside gorm (see enum above)
+ *    - catalogDb - opened system (or any other) database
+ *    - dbName - database name
+ *    - collation - desired collation (could be nil)
+ * Returns nil if collation matches or there is nothing to compare, DbError with CollationMismatchError otherwise or
+ * DbError with ErrInvalidIdentifier if Mssql collation name could not be compared
+ */
+func verifyDatabaseCollation(ctx context.Context, dialect SqlDialect, catalogDb *g.DB, dbName string,
+	collation *Collation) error {
+	if collation == nil || len(collation.Encoding) == 0 || len(getDatabaseCollationQuery(dialect)) == 0 {
+		return nil
+	}
+	if dialect == Mssql && !strings.Contains(collation.Encoding, "_") {
+		// Mssql Encoding is a collation name (i.e. Latin1_General_100_CI_AS), charset name (i.e. utf8) that was
+		// suggested by earlier docs could not be compared with it, verification was requested therefore it is not skipped
+		return newDbError(ErrOpenFailed, dialect, dbName, newWrappedError(ErrInvalidIdentifier, nil,
+			"Mssql collation name (i.e. Latin1_General_100_CI_AS) is expected to verify collation, got \"{0}\"",
+			collation.Encoding))
+	}
+	actual, err := queryDatabaseCollation(ctx, dialect, catalogDb, dbName)
+	if err != nil {
+		return err
+	}
+	mismatches := CompareCollation(collation, actual)
+	if len(mismatches) > 0 {
+		return newDbError(ErrOpenFailed, dialect, dbName, &CollationMismatchError{Mismatches: mismatches})
+	}
+	return nil
+}
+
+// getDatabaseCollationQuery
+/* Function that returns catalog query that selects encoding, collation and ctype (Postgres) of database with name
+ * passed as a parameter
+ * Parameters:
+ *     - dialect - string that represent using db driver inside gorm (see enum above)
+ * Returns query text or empty string for unsupported dialect
+ */
+func getDatabaseCollationQuery(dialect SqlDialect) string {
+	switch dialect {
+	case Postgres:
+		return "SELECT pg_encoding_to_char(encoding) AS encoding_name, datcollate AS collation_name, " +
+			"datctype AS ctype_name FROM pg_database WHERE datname = ?"
+	case Mssql:
+		return "SELECT ISNULL(collation_name, '') AS encoding_name FROM sys.databases WHERE name = ?"
+	case Mysql:
+		return "SELECT DEFAULT_CHARACTER_SET_NAME AS encoding_name, DEFAULT_COLLATION_NAME AS collation_name " +
+			"FROM information_schema.SCHEMATA WHERE SCHEMA_NAME = ?"
+	default:
+		return ""
+	}
+}
+
+// isSameCollationValue
+/* Function that compares collation values case-insensitive and without dashes, locale names are normalized
+ */
+func isSameCollationValue(expected string, actual string) bool {
+	return normalizeCollationValue(expected) == normalizeCollationValue(actual)
+}
+
+// normalizeCollationValue
+/* Function that normalizes collation value: it is lowercased and dashes are removed, codeset of locale name
+ * (language_TERRITORY.codeset@modifier) is normalized like glibc does (only letters and digits are left), POSIX locale is
+ * an alias of C
+ */
+func normalizeCollationValue(value string) string {
+	normalized := strings.ToLower(value)
+	if normalized == "posix" {
+		return "c"
+	}
+	codesetStart := strings.Index(normalized, ".")
+	if codesetStart < 0 {
+		return strings.ReplaceAll(normalized, "-", "")
+	}
+	codeset := normalized[codesetStart+1:]
+	modifier := ""
+	if modifierStart := strings.Index(codeset, "@"); modifierStart >= 0 {
+		modifier = codeset[modifierStart:]
+		codeset = codeset[:modifierStart]
+	}
+	codeset = strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return -1
+	}, codeset)
+	return normalized[:codesetStart] + "." + codeset + modifier
+}
@@ -0,0 +1,91 @@
+package gorm
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"testing"
+)
+
+func TestCompareCollation(t *testing.T) {
+	actual := Collation{Encoding: "UTF8", Parameters: map[string]string{PostgresLcCollateParam: "en_US.utf8",
+		PostgresLcCtypeParam: "en_US.utf8"}}
+	// TEMPLATE is not a database property, dashes and case are ignored
+	expected := Collation{Encoding: "utf-8", Parameters: map[string]string{"lc_collate": "en_US.UTF-8",
+		"TEMPLATE": "template0"}}
+	assert.Empty(t, CompareCollation(&expected, &actual))
+	assert.Empty(t, CompareCollation(nil, &actual))
+	assert.Empty(t, CompareCollation(&Collation{}, &actual))
+
+	expected = Collation{Encoding: "LATIN1", Parameters: map[string]string{"LC_COLLATE": "C", "LC_CTYPE": "en_US.utf8"}}
+	mismatches := CompareCollation(&expected, &actual)
+	assert.Equal(t, []CollationMismatch{
+		{Option: "Encoding", Expected: "LATIN1", Actual: "UTF8"},
+		{Option: PostgresLcCollateParam, Expected: "C", Actual: "en_US.utf8"},
+	}, mismatches)
+}
+
+func TestCollationMismatchError(t *testing.T) {
+	mismatch := CollationMismatch{Option: "Encoding", Expected: "utf8mb4", Actual: "latin1"}
+	err := newDbError(ErrOpenFailed, Mysql, "app", &CollationMismatchError{Mismatches: []CollationMismatch{mismatch}})
+	assert.True(t, errors.Is(err, ErrCollationMismatch))
+	assert.True(t, errors.Is(err, ErrOpenFailed))
+	var mismatchErr *CollationMismatchError
+	assert.True(t, errors.As(err, &mismatchErr))
+	assert.Equal(t, mismatch, mismatchErr.Mismatches[0])
+	assert.Contains(t, err.Error(), "Encoding: expected \"utf8mb4\", actual \"latin1\"")
+}
+
+func TestVerifyMssqlDatabaseCollationWithCharsetName(t *testing.T) {
+	// charset name could not be compared with Mssql collation name, database is not queried
+	err := verifyDatabaseCollation(context.Background(), Mssql, nil, "app", &Collation{Encoding: "utf8"})
+	assert.True(t, errors.Is(err, ErrInvalidIdentifier))
+	assert.True(t, errors.Is(err, ErrOpenFailed))
+	assert.False(t, errors.Is(err, ErrCollationMismatch))
+}
+
+func TestGetSqliteDatabaseCollation(t *testing.T) {
+	collation, err := GetDatabaseCollation(Sqlite, "", "app.db")
+	assert.Nil(t, collation)
+	assert.True(t, errors.Is(err, ErrUnsupportedDialect))
+}
+
+func TestPostgresDatabaseCollation(t *testing.T) {
+	cfg := gorm.Config{}
+	db, connStr := CreateTestDb(t, Postgres, &cfg, &postgresCollation)
+	CloseDb(db)
+	systemDbConnStr, dbName := createSystemDbConnStr(Postgres, &connStr)
+	collation, err := GetDatabaseCollation(Postgres, systemDbConnStr, dbName)
+	assert.NoError(t, err)
+	assert.Equal(t, "UTF8", collation.Encoding)
+	assert.Equal(t, "C", collation.Parameters[PostgresLcCollateParam])
+	assert.Equal(t, "C", collation.Parameters[PostgresLcCtypeParam])
+
+	db, err = OpenDb2WithError(Postgres, connStr, true, true, &cfg, &postgresCollation)
+	assert.NoError(t, err)
+	CloseDb(db)
+	// verification is opt-in, legacy open does not compare collation
+	wrongCollation := Collation{Encoding: "LATIN1"}
+	db, err = OpenDb2WithError(Postgres, connStr, true, true, &cfg, &wrongCollation)
+	assert.NoError(t, err)
+	CloseDb(db)
+	db, err = OpenDb2Context(context.Background(), Postgres, connStr, true, true, &cfg, &wrongCollation,
+		&OpenOptions{VerifyCollation: true})
+	assert.Nil(t, db)
+	assert.True(t, errors.Is(err, ErrCollationMismatch))
+}
+
+func TestCompareCollationNormalizesLocales(t *testing.T) {
+	actual := Collation{Encoding: "UTF8", Parameters: map[string]string{PostgresLcCollateParam: "en_US.utf8",
+		PostgresLcCtypeParam: "C"}}
+	expected := Collation{Encoding: "UTF8", Parameters: map[string]string{"LC_COLLATE": "en_US.UTF_8",
+		"LC_CTYPE": "POSIX"}}
+	assert.Empty(t, CompareCollation(&expected, &actual))
+
+	actual.Parameters[PostgresLcCollateParam] = "de_DE.utf8@euro"
+	expected.Parameters["LC_COLLATE"] = "de_DE.UTF-8"
+	assert.Equal(t, []CollationMismatch{
+		{Option: PostgresLcCollateParam, Expected: "de_DE.UTF-8", Actual: "de_DE.utf8@euro"},
+	}, CompareCollation(&expected, &actual))
+}
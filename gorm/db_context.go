@@ -1,17 +1,19 @@
 package gorm
 
 import (
-	"github.com/gofrs/uuid"
+	"context"
+	"database/sql"
+	"errors"
 	_ "github.com/jinzhu/gorm/dialects/mssql"
 	_ "github.com/jinzhu/gorm/dialects/mysql"
 	_ "github.com/jinzhu/gorm/dialects/postgres"
 	"github.com/wissance/stringFormatter"
 	"gorm.io/driver/mysql"
 	"gorm.io/driver/postgres"
-	//"gorm.io/driver/sqlite"
+	"gorm.io/driver/sqlite"
 	"gorm.io/driver/sqlserver"
 	g "gorm.io/gorm"
-	"strings"
+	"os"
 )
 
 type SqlDialect string
@@ -27,7 +29,8 @@ const (
 /* For Postgres we should pass Collation as Collation {Encoding: "UTF8", Params: map[string]string {"LC_COLLATE": "en_US.utf8",
  *                                                                                                  "LC_CTYPE": "en_US.utf8"}
  * For Mysql we should pass Collation {Encoding: "utf8mb4", Params: map[string]string {"COLLATE": "utf8mb4_unicode_ci"}
- * For Mssql we should pass Collation {Encoding: "utf8", Params: map[string]string{}
+ * For Mssql we should pass collation name as Encoding: Collation {Encoding: "Latin1_General_100_CI_AS_SC_UTF8",
+ *                                                                 Params: map[string]string{}
  */
 type Collation struct {
 	// Encoding represents string encoding type
@@ -42,15 +45,14 @@ type Collation struct {
 	Parameters map[string]string
 }
 
-const postgresConnStrTemplate = "host={0} port={1} user={2} dbname={3} password={4} sslmode={5}"
-const mssqlConnStrTemplate = "sqlserver://{username}:{password}@{host}:{port}?database={dbname}"
-
-// todo: umv: think about charset as parameter
-const mysqlConnStrTemplate = "{username}:{password}@tcp({host}:{port})/{dbname}?charset=utf8mb4&parseTime=True&loc=Local"
 const postgresSystemDb = "postgres"
 const mssqlSystemDb = "master"
 const mysqlSystemDb = "mysql"
 
+const sqliteMemoryDb = ":memory:"
+const sqliteFilePrefix = "file:"
+const sqliteDbFileExtension = ".db"
+
 const tmpDatabaseNameTemplate = "wissance_tmp_db_{0}"
 
 const postgresCollationOptionsTemplate = " ENCODING '{0}' {1} "
@@ -68,8 +70,8 @@ const mssqlCollationOptionsTemplate = " COLLATE {0} "
  *    - dbName - database/catalog/schema name
  *    - dbUser - user that is using for perform operations on dbName
  *    - password - dbUser password
- *    - useSsl - is a string value that currently is using with Postgres Sql Only (allowed options are: disable, and others for enable)
- *    - collation a set of charset / collation options for database creation
+ *    - useSsl - sslmode (Postgres), tls (Mysql) or encrypt (Mssql) parameter value, empty value means not set
+ * Connection string is built by ConnectionConfig (see NewConnectionConfig) therefore credentials are escaped
  * Returns database connection string
  */
 func BuildConnectionString(dialect SqlDialect, host string, port int, dbName string, dbUser string, password string, useSsl string) string {
@@ -79,7 +81,9 @@ func BuildConnectionString(dialect SqlDialect, host string, port int, dbName str
 // CreateRandomDb
 // Function that Create and Open database with random name
 // this function should be used for testing purposes create temporary database for test
-/* Parameters:
+/* For Sqlite database is created as a file in temporary directory (os.TempDir()), host, port, dbUser and password
+ * are ignored, DropDb removes that file
+ * Parameters:
  *    - dialect - string that represent using db driver inside gorm (see enum above)
  *    - host - ip address / hostname of machine where database server is located
  *    - port - integer value representing server tcp port (typically 5432 for postgres, 3306 for mysql and 1433 for mssql)
@@ -91,10 +95,36 @@ func BuildConnectionString(dialect SqlDialect, host string, port int, dbName str
  */
 func CreateRandomDb(dialect SqlDialect, host string, port int, dbUser string, password string,
 	useSsl string, options *g.Config, collation *Collation) (*g.DB, string) {
-	random, _ := uuid.NewV4()
-	dbName := stringFormatter.Format(tmpDatabaseNameTemplate, strings.Replace(random.String(), "-", "", -1))
+	db, connStr, _ := CreateRandomDbWithError(dialect, host, port, dbUser, password, useSsl, options, collation)
+	return db, connStr
+}
+
+// CreateRandomDbWithError
+/* Function that does same as CreateRandomDb but returns error (DbError) if database was not created or opened
+ * Parameters are the same as in CreateRandomDb
+ * Returns tuple of gorm.DB address of database context object, connStr and error
+ */
+func CreateRandomDbWithError(dialect SqlDialect, host string, port int, dbUser string, password string,
+	useSsl string, options *g.Config, collation *Collation) (*g.DB, string, error) {
+	return CreateRandomDbContext(context.Background(), dialect, host, port, dbUser, password, useSsl, options, collation,
+		nil)
+}
+
+// CreateRandomDbContext
+/* Function that does same as CreateRandomDbWithError but connection to server and CREATE DATABASE could be cancelled or
+ * bounded by ctx deadline, ctx is not stored in returned database context
+ * Parameters:
+ *    - ctx - context that bounds database creation and opening
+ *    - openOptions - connection pool and session options (could be nil)
+ *    - other parameters are the same as in CreateRandomDb
+ * Returns tuple of gorm.DB address of database context object, connStr and error
+ */
+func CreateRandomDbContext(ctx context.Context, dialect SqlDialect, host string, port int, dbUser string, password string,
+	useSsl string, options *g.Config, collation *Collation, openOptions *OpenOptions) (*g.DB, string, error) {
+	dbName := createRandomDbName(dialect, tmpDatabaseNameTemplate)
 	connStr := BuildConnectionString(dialect, host, port, dbName, dbUser, password, useSsl)
-	return OpenDb2(dialect, connStr, true, false, options, collation), connStr
+	db, err := OpenDb2Context(ctx, dialect, connStr, true, false, options, collation, openOptions)
+	return db, connStr, err
 }
 
 // OpenDb
@@ -119,8 +149,35 @@ func CreateRandomDb(dialect SqlDialect, host string, port int, dbUser string, pa
  */
 func OpenDb(dialect SqlDialect, host string, port int, dbName string, dbUser string, password string,
 	useSsl string, create bool, check bool, options *g.Config, collation *Collation) *g.DB {
+	db, _ := OpenDbWithError(dialect, host, port, dbName, dbUser, password, useSsl, create, check, options, collation)
+	return db
+}
+
+// OpenDbWithError
+/* Function that does same as OpenDb but returns error (DbError) instead of nil database context
+ * Parameters are the same as in OpenDb
+ * Returns tuple of gorm.DB address of database context object and error
+ */
+func OpenDbWithError(dialect SqlDialect, host string, port int, dbName string, dbUser string, password string,
+	useSsl string, create bool, check bool, options *g.Config, collation *Collation) (*g.DB, error) {
+	return OpenDbContext(context.Background(), dialect, host, port, dbName, dbUser, password, useSsl, create, check,
+		options, collation, nil)
+}
+
+// OpenDbContext
+/* Function that does same as OpenDbWithError but dialing, existence check and CREATE DATABASE could be cancelled or
+ * bounded by ctx deadline, ctx is not stored in returned database context
+ * Parameters:
+ *    - ctx - context that bounds database opening
+ *    - openOptions - connection pool and session options (could be nil)
+ *    - other parameters are the same as in OpenDb
+ * Returns tuple of gorm.DB address of database context object and error
+ */
+func OpenDbContext(ctx context.Context, dialect SqlDialect, host string, port int, dbName string, dbUser string,
+	password string, useSsl string, create bool, check bool, options *g.Config, collation *Collation,
+	openOptions *OpenOptions) (*g.DB, error) {
 	connStr := createConnStr(dialect, host, port, dbName, dbUser, password, useSsl)
-	return OpenDb2(dialect, connStr, create, check, options, collation)
+	return OpenDb2Context(ctx, dialect, connStr, create, check, options, collation, openOptions)
 }
 
 // OpenDb2
@@ -131,54 +188,273 @@ func OpenDb(dialect SqlDialect, host string, port int, dbName string, dbUser str
  *    - dialect - string that represent using db driver inside gorm (see enum above)
  *    - connStr - full connection string
  *    - create - if true we should create database if it does not exist
- *    - check - if true existence of database is checking otherwise not (we sure that this is a random database and to save
- *              some time we could omit existence check)
+ *    - check - if true existence of database is checking (in server catalog, see DatabaseExists) otherwise not (we sure
+ *              that this is a random database and to save some time we could omit existence check)
  *    - options - gorm config (from gorm.io/gorm NOT from github.com/jinzhu/gorm)
  *    - collation a set of charset / collation options for database creation
  */
 func OpenDb2(dialect SqlDialect, connStr string, create bool, check bool, options *g.Config, collation *Collation) *g.DB {
-	// by default, we set dbCheckResult to true (for case when check is not needed)
+	db, _ := OpenDb2WithError(dialect, connStr, create, check, options, collation)
+	return db
+}
+
+// OpenDb2WithError
+/* Function that does same as OpenDb2 but returns error (DbError) instead of nil database context, error could be
+ * checked with errors.Is (i.e. ErrDatabaseNotFound, ErrAuthenticationFailed, ErrCreateFailed)
+ * Parameters are the same as in OpenDb2
+ * Returns tuple of gorm.DB address of database context object and error
+ */
+func OpenDb2WithError(dialect SqlDialect, connStr string, create bool, check bool, options *g.Config,
+	collation *Collation) (*g.DB, error) {
+	return OpenDb2Context(context.Background(), dialect, connStr, create, check, options, collation, nil)
+}
+
+// OpenDb2Context
+/* Function that does same as OpenDb2WithError but dialing, existence check and CREATE DATABASE could be cancelled or
+ * bounded by ctx deadline (i.e. when database host is unreachable), ctx is not stored in returned database context
+ * Parameters:
+ *    - ctx - context that bounds database opening
+ *    - openOptions - connection pool limits and session settings that are applied to every new pooled connection,
+ *                    retry policy of waiting for database, if VerifyCollation is true and checked database exists
+ *                    its collation is verified (error matches ErrCollationMismatch) (could be nil)
+ *    - other parameters are the same as in OpenDb2
+ * Returns tuple of gorm.DB address of database context object and error, if ctx was cancelled or its deadline
+ * exceeded error matches context.Canceled or context.DeadlineExceeded
+ */
+func OpenDb2Context(ctx context.Context, dialect SqlDialect, connStr string, create bool, check bool, options *g.Config,
+	collation *Collation, openOptions *OpenOptions) (*g.DB, error) {
+	openOrCreate := func(attemptCtx context.Context, create bool) (*g.DB, error) {
+		return openOrCreateDb(attemptCtx, dialect, connStr, create, check, options, collation, openOptions)
+	}
+	if openOptions != nil && openOptions.Retry != nil {
+		return retryOpenOrCreate(ctx, openOptions.Retry, create, openOrCreate)
+	}
+	return openOrCreate(ctx, create)
+}
+
+// retryOpenOrCreate
+/* Function that repeats opening (and creation) of database with retry policy, if attempt created database but failed
+ * to open it, next attempts get ErrDatabaseExists, therefore after first attempt existing database is just opened
+ * Parameters:
+ *    - ctx - context that bounds all attempts
+ *    - policy - retry policy
+ *    - create - whether database should be created
+ *    - openOrCreate - single attempt of opening (see openOrCreateDb)
+ * Returns tuple of gorm.DB address of database context object and error of last attempt
+ */
+func retryOpenOrCreate(ctx context.Context, policy *RetryPolicy, create bool,
+	openOrCreate func(ctx context.Context, create bool) (*g.DB, error)) (*g.DB, error) {
+	attempt := 0
+	return retry(ctx, policy, func(attemptCtx context.Context) (*g.DB, error) {
+		attempt++
+		db, err := openOrCreate(attemptCtx, create)
+		if err != nil && create && attempt > 1 && errors.Is(err, ErrDatabaseExists) {
+			// database was created by one of previous attempts
+			return openOrCreate(attemptCtx, false)
+		}
+		return db, err
+	})
+}
+
+// openOrCreateDb
+/* Function that checks database existence (if check is true), creates it (if create is true) and opens it, this is a
+ * single attempt of OpenDb2Context, parameters are the same
+ * Returns tuple of gorm.DB address of database context object and error
+ */
+func openOrCreateDb(ctx context.Context, dialect SqlDialect, connStr string, create bool, check bool, options *g.Config,
+	collation *Collation, openOptions *OpenOptions) (*g.DB, error) {
+	// by default, we set dbCheckResult to false (for case when check is not needed we create database)
 	dbCheckResult := false
 	if check {
-		// we check if check is true
-		dbCheckResult = CheckDb(dialect, connStr, options)
+		// we check if check is true, database existence is checked in catalog of server, if connStr does not
+		// contain database name or system database is not available, we could only try to open it
+		var verifiedCollation *Collation
+		if openOptions != nil && openOptions.VerifyCollation {
+			// existing database could be created with other charset
+			verifiedCollation = collation
+		}
+		exists, err := checkDbExistence(ctx, dialect, connStr, options, verifiedCollation)
+		if err != nil {
+			return nil, err
+		}
+		dbCheckResult = exists
 	}
 	if create == false {
 		if dbCheckResult == false && check == true {
-			return nil
+			_, dbName := createSystemDbConnStr(dialect, &connStr)
+			return nil, newDbError(ErrOpenFailed, dialect, dbName, ErrDatabaseNotFound)
 		}
 	} else {
 		if !dbCheckResult {
 			systemDbConnStr, dbName := createSystemDbConnStr(dialect, &connStr)
-			return createDb(dialect, &systemDbConnStr, &connStr, &dbName, options, collation)
+			return createDb(ctx, dialect, &systemDbConnStr, &connStr, &dbName, options, collation, openOptions)
 		}
 	}
 
-	db, err := g.Open(createDialector(dialect, connStr), options)
-	if err != nil {
-		return nil
-	}
-
-	return db
+	return openDb(ctx, dialect, connStr, options, openOptions)
 }
 
 // CheckDb
 /* Functions that checks if database exists or not
+ * For Sqlite we check database file existence (in-memory database always exists) because sqlite driver creates
+ * database file on open
  * Parameters:
  *    - dialect - string that represent using db driver inside gorm (see enum above)
  *    - connStr - full connection string
  * Returns true if database exists otherwise false
  */
 func CheckDb(dialect SqlDialect, dbConnStr string, options *g.Config) bool {
-	db, err := g.Open(createDialector(dialect, dbConnStr), options)
+	exists, _ := CheckDbWithError(dialect, dbConnStr, options)
+	return exists
+}
+
+// CheckDbWithError
+/* Function that does same as CheckDb but distinguishes missing database from other failures (wrong credentials,
+ * permission denial, unreachable server): if target database could not be opened and the driver error does not say
+ * why, system database is opened with same credentials, if it succeeded target database does not exist
+ * Parameters are the same as in CheckDb
+ * Returns (true, nil) if database exists, (false, nil) if it does not exist and (false, DbError) on other failures
+ */
+func CheckDbWithError(dialect SqlDialect, dbConnStr string, options *g.Config) (bool, error) {
+	return CheckDbContext(context.Background(), dialect, dbConnStr, options)
+}
+
+// CheckDbContext
+/* Function that does same as CheckDbWithError but connection to server could be cancelled or bounded by ctx deadline
+ * Parameters:
+ *    - ctx - context that bounds check
+ *    - other parameters are the same as in CheckDb
+ * Returns (true, nil) if database exists, (false, nil) if it does not exist and (false, DbError) on other failures
+ */
+func CheckDbContext(ctx context.Context, dialect SqlDialect, dbConnStr string, options *g.Config) (bool, error) {
+	if dialect == Sqlite {
+		return checkSqliteDbFile(dbConnStr), nil
+	}
+	systemDbConnStr, dbName := createSystemDbConnStr(dialect, &dbConnStr)
+	db, err := openDb(ctx, dialect, dbConnStr, options, nil)
 	if err == nil {
-		sqlDb, dbErr := db.DB()
-		if dbErr == nil && sqlDb != nil {
-			_ = sqlDb.Close()
+		_ = CloseDbWithError(db)
+		return true, nil
+	}
+	if errors.Is(err, ErrDatabaseNotFound) {
+		return false, nil
+	}
+	if errors.Is(err, ErrUnsupportedDialect) || errors.Is(err, ErrAuthenticationFailed) ||
+		errors.Is(err, ErrPermissionDenied) || ctx.Err() != nil || len(systemDbConnStr) == 0 {
+		return false, err
+	}
+	systemDb, systemErr := openDb(ctx, dialect, systemDbConnStr, options, nil)
+	if systemErr != nil {
+		return false, newDbErrorContext(ctx, ErrOpenFailed, dialect, dbName, errors.Unwrap(systemErr))
+	}
+	_ = CloseDbWithError(systemDb)
+	return false, nil
+}
+
+// DatabaseExists
+/* Function that checks database existence in server catalog (pg_database for Postgres, information_schema.SCHEMATA
+ * for Mysql and sys.databases for Mssql) through system database, unlike CheckDb it does not try to open target
+ * database therefore it doesn't depend on driver behaviour (lazy connect) and on target database permissions.
+ * Mysql shows only databases on which user has some privilege, therefore database without grants is reported as missing.
+ * For Sqlite we check database file existence, systemDbConnStr is not used
+ * Parameters:
+ *    - dialect - string that represent using db driver inside gorm (see enum above)
+ *    - systemDbConnStr - connection string to system database (see createSystemDbConnStr)
+ *    - dbName - name of database to check (path to file for Sqlite)
+ * Returns (true, nil) if database exists, (false, nil) if it does not exist and (false, DbError) if check failed
+ */
+func DatabaseExists(dialect SqlDialect, systemDbConnStr string, dbName string) (bool, error) {
+	return DatabaseExistsContext(context.Background(), dialect, systemDbConnStr, dbName, &g.Config{})
+}
+
+// DatabaseExistsContext
+/* Function that does same as DatabaseExists but connection to server and catalog query could be cancelled or bounded
+ * by ctx deadline
+ * Parameters:
+ *    - ctx - context that bounds check
+ *    - options - gorm config (from gorm.io/gorm NOT from github.com/jinzhu/gorm)
+ *    - other parameters are the same as in DatabaseExists
+ * Returns (true, nil) if database exists, (false, nil) if it does not exist and (false, DbError) if check failed
+ */
+func DatabaseExistsContext(ctx context.Context, dialect SqlDialect, systemDbConnStr string, dbName string,
+	options *g.Config) (bool, error) {
+	if dialect == Sqlite {
+		return checkSqliteDbFile(dbName), nil
+	}
+	if len(getDatabaseExistsQuery(dialect)) == 0 {
+		return false, newDbError(ErrOpenFailed, dialect, dbName, ErrUnsupportedDialect)
+	}
+	systemDb, err := openDb(ctx, dialect, systemDbConnStr, options, nil)
+	if err != nil {
+		return false, newDbErrorContext(ctx, ErrOpenFailed, dialect, dbName, errors.Unwrap(err))
+	}
+	defer CloseDb(systemDb)
+	return queryDatabaseExists(ctx, dialect, systemDb, dbName)
+}
+
+// queryDatabaseExists
+/* Function that checks database existence in server catalog through opened database (catalog is available from any
+ * database of server)
+ * Parameters:
+ *    - ctx - context that bounds query
+ *    - dialect - string that represent using db driver inside gorm (see enum above)
+ *    - catalogDb - opened system (or any other) database
+ *    - dbName - name of database to check
+ * Returns (true, nil) if database exists, (false, nil) if it does not exist and (false, DbError) if query failed
+ */
+func queryDatabaseExists(ctx context.Context, dialect SqlDialect, catalogDb *g.DB, dbName string) (bool, error) {
+	var count int64
+	err := catalogDb.WithContext(ctx).Raw(getDatabaseExistsQuery(dialect), dbName).Scan(&count).Error
+	if err != nil {
+		return false, newDbErrorContext(ctx, ErrOpenFailed, dialect, dbName, err)
+	}
+	return count > 0, nil
+}
+
+// checkDbExistence
+/* Function that checks database existence and collation of existing database (if collation is passed) in server
+ * catalog through single system database connection. Application users often could not open system database (Mysql
+ * mysql, Mssql master) or read catalog, in this case target database is opened instead (see CheckDbContext) and its
+ * collation is read through it
+ * Parameters:
+ *    - ctx - context that bounds check
+ *    - dialect - string that represent using db driver inside gorm (see enum above)
+ *    - connStr - full connection string of target database
+ *    - options - gorm config
+ *    - collation - desired collation (could be nil)
+ * Returns (true, nil) if database exists, (false, nil) if it does not exist and (false, DbError) if check failed or
+ * collation does not match
+ */
+func checkDbExistence(ctx context.Context, dialect SqlDialect, connStr string, options *g.Config,
+	collation *Collation) (bool, error) {
+	systemDbConnStr, dbName := createSystemDbConnStr(dialect, &connStr)
+	if dialect == Sqlite {
+		return checkSqliteDbFile(dbName), nil
+	}
+	if len(systemDbConnStr) == 0 || len(getDatabaseExistsQuery(dialect)) == 0 {
+		return CheckDbContext(ctx, dialect, connStr, options)
+	}
+	systemDb, err := openDb(ctx, dialect, systemDbConnStr, options, nil)
+	if err == nil {
+		exists, queryErr := queryDatabaseExists(ctx, dialect, systemDb, dbName)
+		if queryErr == nil && exists {
+			queryErr = verifyDatabaseCollation(ctx, dialect, systemDb, dbName, collation)
 		}
-		return true
+		CloseDb(systemDb)
+		if queryErr == nil || !IsPermissionDenied(queryErr) {
+			return exists && queryErr == nil, queryErr
+		}
+	} else if ctx.Err() != nil {
+		return false, newDbErrorContext(ctx, ErrOpenFailed, dialect, dbName, errors.Unwrap(err))
+	}
+	// system database is not available, target database is opened
+	targetDb, err := openDb(ctx, dialect, connStr, options, nil)
+	if err != nil {
+		// CheckDbContext distinguishes missing database from other failures
+		return CheckDbContext(ctx, dialect, connStr, options)
 	}
-	return false
+	defer CloseDb(targetDb)
+	return true, verifyDatabaseCollation(ctx, dialect, targetDb, dbName, collation)
 }
 
 // CloseDb
@@ -188,16 +464,27 @@ func CheckDb(dialect SqlDialect, dbConnStr string, options *g.Config) bool {
  * Returns true on success
  */
 func CloseDb(db *g.DB) bool {
-	if db != nil {
-		sqlDB, err := db.DB()
-		if err == nil && sqlDB != nil {
-			err = sqlDB.Close()
-			if err == nil {
-				return true
-			}
-		}
+	return CloseDbWithError(db) == nil
+}
+
+// CloseDbWithError
+/* Function that does same as CloseDb but returns error (DbError with ErrCloseFailed kind)
+ * Parameters:
+ *    - db - address of database context object
+ * Returns nil on success
+ */
+func CloseDbWithError(db *g.DB) error {
+	if db == nil {
+		return &DbError{Kind: ErrCloseFailed, Err: errors.New("database context is nil")}
+	}
+	sqlDB, err := db.DB()
+	if err == nil {
+		err = sqlDB.Close()
 	}
-	return false
+	if err != nil {
+		return &DbError{Kind: ErrCloseFailed, Err: err}
+	}
+	return nil
 }
 
 // DropDb
@@ -207,8 +494,32 @@ func CloseDb(db *g.DB) bool {
  *    - connStr - full connection string
  */
 func DropDb(dialect SqlDialect, connStr string, options *g.Config) bool {
+	return DropDbWithError(dialect, connStr, options) == nil
+}
+
+// DropDbWithError
+/* Function that does same as DropDb but returns error (DbError with ErrDropFailed kind)
+ * Parameters are the same as in DropDb
+ * Returns nil if database was dropped
+ */
+func DropDbWithError(dialect SqlDialect, connStr string, options *g.Config) error {
+	return DropDbContext(context.Background(), dialect, connStr, options)
+}
+
+// DropDbContext
+/* Function that does same as DropDbWithError but connection to server and DROP DATABASE could be cancelled or bounded
+ * by ctx deadline
+ * Parameters:
+ *    - ctx - context that bounds database dropping
+ *    - other parameters are the same as in DropDb
+ * Returns nil if database was dropped
+ */
+func DropDbContext(ctx context.Context, dialect SqlDialect, connStr string, options *g.Config) error {
 	systemDbConnStr, dbName := createSystemDbConnStr(dialect, &connStr)
-	return DropDb2(dialect, systemDbConnStr, dbName, options)
+	if len(dbName) == 0 {
+		return newDbError(ErrDropFailed, dialect, dbName, ErrInvalidConnStr)
+	}
+	return DropDb2Context(ctx, dialect, systemDbConnStr, dbName, options)
 }
 
 // DropDb2
@@ -216,70 +527,157 @@ func DropDb(dialect SqlDialect, connStr string, options *g.Config) bool {
  * Parameters:
  *     - dialect - string that represent using db driver inside gorm (see enum above)
  *     - systemDbConnStr - connection string to system database (in mysql - mysql, in sqlserver - master,
- *                         in postgres - postgres)
- *     - dbName - name of database that should be deleted
+ *                         in postgres - postgres, sqlite doesn't have system database, value is ignored)
+ *     - dbName - name of database that should be deleted (for sqlite - path to database file), it is validated and
+ *                quoted according to dialect (see QuoteIdentifier)
  * Returns true if database was deleted / dropped
  */
 func DropDb2(dialect SqlDialect, systemDbConnStr string, dbName string, options *g.Config) bool {
-	db, err := g.Open(createDialector(dialect, systemDbConnStr), options)
+	return DropDb2WithError(dialect, systemDbConnStr, dbName, options) == nil
+}
+
+// DropDb2WithError
+/* Function that does same as DropDb2 but returns error (DbError with ErrDropFailed kind)
+ * Parameters are the same as in DropDb2
+ * Returns nil if database was dropped
+ */
+func DropDb2WithError(dialect SqlDialect, systemDbConnStr string, dbName string, options *g.Config) error {
+	return DropDb2Context(context.Background(), dialect, systemDbConnStr, dbName, options)
+}
+
+// DropDb2Context
+/* Function that does same as DropDb2WithError but connection to server and DROP DATABASE could be cancelled or bounded
+ * by ctx deadline
+ * Parameters:
+ *    - ctx - context that bounds database dropping
+ *    - other parameters are the same as in DropDb2
+ * Returns nil if database was dropped
+ */
+func DropDb2Context(ctx context.Context, dialect SqlDialect, systemDbConnStr string, dbName string,
+	options *g.Config) error {
+	if dialect == Sqlite {
+		err := dropSqliteDbFile(dbName)
+		if err != nil {
+			return newDbError(ErrDropFailed, dialect, dbName, err)
+		}
+		return nil
+	}
+	quotedDbName, err := QuoteIdentifier(dialect, dbName)
+	if err != nil {
+		return newDbError(ErrDropFailed, dialect, dbName, err)
+	}
+	db, err := openDb(ctx, dialect, systemDbConnStr, options, nil)
+	if err != nil {
+		return newDbErrorContext(ctx, ErrDropFailed, dialect, dbName, errors.Unwrap(err))
+	}
+	defer CloseDb(db)
+	dropDbStatement := stringFormatter.Format("DROP DATABASE IF EXISTS {0}", quotedDbName)
+	err = db.WithContext(ctx).Exec(dropDbStatement).Error
+	if err != nil {
+		return newDbErrorContext(ctx, ErrDropFailed, dialect, dbName, err)
+	}
+	return nil
+}
+
+// openDb
+/* Function that opens database with gorm and wraps error into DbError with ErrOpenFailed kind. Connection pool is
+ * opened and pinged before gorm initialization because gorm pings database without context
+ * Parameters:
+ *    - ctx - context that bounds dialing
+ *    - dialect - string that represent using db driver inside gorm (see enum above)
+ *    - connStr - full connection string
+ *    - options - gorm config
+ *    - openOptions - pool and session options (could be nil)
+ * Returns tuple of gorm.DB address of database context object and error
+ */
+func openDb(ctx context.Context, dialect SqlDialect, connStr string, options *g.Config,
+	openOptions *OpenOptions) (*g.DB, error) {
+	_, dbName := createSystemDbConnStr(dialect, &connStr)
+	driverName := getSqlDriverName(dialect)
+	if len(driverName) == 0 {
+		return nil, newDbError(ErrOpenFailed, dialect, dbName, ErrUnsupportedDialect)
+	}
+	sqlDb, connStr, err := openSqlDb(dialect, connStr, openOptions)
+	if err != nil {
+		return nil, newDbErrorContext(ctx, ErrOpenFailed, dialect, dbName, err)
+	}
+	err = sqlDb.PingContext(ctx)
 	if err != nil {
-		return false
+		_ = sqlDb.Close()
+		return nil, newDbErrorContext(ctx, ErrOpenFailed, dialect, dbName, err)
 	}
-	dropDbStatement := stringFormatter.Format("DROP DATABASE IF EXISTS {0}", dbName)
-	err = db.Exec(dropDbStatement).Error
+	db, err := g.Open(createDialector(dialect, connStr, sqlDb), options)
 	if err != nil {
-		CloseDb(db)
-		return false
+		_ = sqlDb.Close()
+		return nil, newDbErrorContext(ctx, ErrOpenFailed, dialect, dbName, err)
 	}
-	CloseDb(db)
-	return true
+	return db, nil
 }
 
 // createSystemDbConnStr
 /* Function that creates system database connection string from target database connection string
  * Create system db conn string using connection string to open target database, but database could not exist
  * therefore in some cases we have to create it (if we pass create=true to any OpenDb function).
- * In this function we are processing target db connStr and replace database name with system database name
+ * In this function we are parsing target db connStr into ConnectionConfig and replace database name with system
+ * database name
  * Parameters:
  *     - dialect - string that represent using db driver inside gorm (see enum above)
  *     - connStr - connection string to other database
- * Return tuple of systemDbConnStr, dbName
+ * Return tuple of systemDbConnStr, dbName (empty strings if connStr could not be parsed), for sqlite systemDbConnStr is
+ * always empty because there is no system database
  */
 func createSystemDbConnStr(dialect SqlDialect, connStr *string) (string, string) {
-	connStrCopy := *connStr
-	if dialect == Postgres {
-		// replace dbname={
-		const postgresDbPattern = "dbname="
-		beginIndex := strings.Index(connStrCopy, postgresDbPattern)
-		if beginIndex < 0 {
-			return "", ""
-		}
-		endIndex := getSymbolIndex(&connStrCopy, ' ', beginIndex+len(postgresDbPattern))
-		dbNameStr := connStrCopy[beginIndex:endIndex]
-		systemDbStr := postgresDbPattern + postgresSystemDb
-		return strings.Replace(connStrCopy, dbNameStr, systemDbStr, 1), dbNameStr[7:]
-
-	} else if dialect == Mssql {
-		const mssqlDbPattern = "?database="
-		beginIndex := strings.Index(connStrCopy, mssqlDbPattern)
-		if beginIndex < 0 {
-			return "", ""
-		}
-		dbNameStr := connStrCopy[beginIndex:]
-		systemDbStr := mssqlDbPattern + mssqlSystemDb
-		return strings.Replace(connStrCopy, dbNameStr, systemDbStr, 1), dbNameStr[10:]
-
-	} else if dialect == Mysql {
-		beginIndex := getSymbolIndex(&connStrCopy, '/', 0)
-		if beginIndex < 0 {
-			return "", ""
-		}
-		endIndex := getSymbolIndex(&connStrCopy, '?', beginIndex)
-		dbNameStr := connStrCopy[beginIndex:endIndex]
-		systemDbStr := "/" + mysqlSystemDb
-		return strings.Replace(connStrCopy, dbNameStr, systemDbStr, 1), dbNameStr[1:]
+	if dialect == Sqlite {
+		// sqlite doesn't have a system database, database name is a path to file
+		return "", getSqliteDbFile(*connStr)
+	}
+	cfg, err := ParseConnectionString(dialect, *connStr)
+	if err != nil || len(cfg.DbName) == 0 {
+		return "", ""
+	}
+	systemDbConnStr, err := cfg.WithDbName(getSystemDbName(dialect)).ConnectionString()
+	if err != nil {
+		return "", ""
+	}
+	return systemDbConnStr, cfg.DbName
+}
+
+// getSystemDbName
+/* Function that returns system database name of dialect
+ * Parameters:
+ *     - dialect - string that represent using db driver inside gorm (see enum above)
+ * Returns system database name (mysql - mysql, postgres - postgres, sqlserver - master)
+ */
+func getSystemDbName(dialect SqlDialect) string {
+	switch dialect {
+	case Postgres:
+		return postgresSystemDb
+	case Mssql:
+		return mssqlSystemDb
+	case Mysql:
+		return mysqlSystemDb
+	default:
+		return ""
+	}
+}
+
+// getDatabaseExistsQuery
+/* Function that returns catalog query that counts databases with name passed as a parameter
+ * Parameters:
+ *     - dialect - string that represent using db driver inside gorm (see enum above)
+ * Returns query text or empty string for unsupported dialect
+ */
+func getDatabaseExistsQuery(dialect SqlDialect) string {
+	switch dialect {
+	case Postgres:
+		return "SELECT COUNT(*) FROM pg_database WHERE datname = ?"
+	case Mssql:
+		return "SELECT COUNT(*) FROM sys.databases WHERE name = ?"
+	case Mysql:
+		return "SELECT COUNT(*) FROM information_schema.SCHEMATA WHERE SCHEMA_NAME = ?"
+	default:
+		return ""
 	}
-	return "", ""
 }
 
 // createConnStr
@@ -291,97 +689,101 @@ func createSystemDbConnStr(dialect SqlDialect, connStr *string) (string, string)
  *    - dbName - database/catalog/schema name
  *    - dbUser - user that is using for perform operations on dbName
  *    - password - dbUser password
- *    - useSsl - is a string value that currently is using with Postgres Sql Only (allowed options are: disable, and others for enable)
+ *    - useSsl - sslmode (Postgres), tls (Mysql) or encrypt (Mssql) parameter value, empty value means not set
+ * For Sqlite only dbName is using, it is a path to database file or :memory:
  * Returns connection string
  */
 func createConnStr(dialect SqlDialect, host string, port int, dbName string,
 	dbUser string, password string, useSsl string) string {
-	connStr := ""
-	if dialect == Postgres {
-		connStr = stringFormatter.Format(postgresConnStrTemplate, host, port, dbUser, dbName, password, useSsl)
-	} else if dialect == Mssql {
-		connStr = stringFormatter.FormatComplex(mssqlConnStrTemplate, map[string]interface{}{
-			"username": dbUser, "password": password, "host": host, "port": port, "dbname": dbName})
-	} else if dialect == Mysql {
-		connStr = stringFormatter.FormatComplex(mysqlConnStrTemplate, map[string]interface{}{
-			"username": dbUser, "password": password, "host": host, "port": port, "dbname": dbName})
-	}
+	connStr, _ := NewConnectionConfig(dialect, host, port, dbName, dbUser, password, useSsl).ConnectionString()
 	return connStr
 }
 
 // createDb
 /* Function that creates database on server
  * Parameters:
+ *    - ctx - context that bounds database creation
  *    - dialect - string that represent using db driver inside gorm (see enum above)
  *    - systemDbConnStr - system (mysql - mysql, postgres - postgres, sqlserver - master) database connection string
  *    - dbConnStr - target database connection string
- *    - dbName - database name
+ *    - dbName - database name, it is validated and quoted according to dialect (see QuoteIdentifier)
  *    - options - gorm context configuration
  *    - collation a set of charset / collation options for database creation
- * Return pointer to database context
+ *    - openOptions - pool and session options of target database (could be nil)
+ * Return tuple of pointer to database context and error (DbError with ErrCreateFailed or ErrOpenFailed kind)
  */
-func createDb(dialect SqlDialect, systemDbConnStr *string, dbConnStr *string, dbName *string, options *g.Config, collation *Collation) *g.DB {
+func createDb(ctx context.Context, dialect SqlDialect, systemDbConnStr *string, dbConnStr *string, dbName *string,
+	options *g.Config, collation *Collation, openOptions *OpenOptions) (*g.DB, error) {
+	if dialect == Sqlite {
+		// sqlite driver creates database file on open, collation is not applicable
+		return openDb(ctx, dialect, *dbConnStr, options, openOptions)
+	}
+	if len(*dbName) == 0 {
+		return nil, newDbError(ErrCreateFailed, dialect, *dbName, ErrInvalidConnStr)
+	}
+	quotedDbName, err := QuoteIdentifier(dialect, *dbName)
+	if err != nil {
+		return nil, newDbError(ErrCreateFailed, dialect, *dbName, err)
+	}
 	// todo(UMV): add collation according to dialect
 	createStatementTemplate := "CREATE DATABASE {0} {1}"
 	collationStatement := createCollationOption(dialect, collation)
-	createStatement := stringFormatter.Format(createStatementTemplate, *dbName, collationStatement)
+	createStatement := stringFormatter.Format(createStatementTemplate, quotedDbName, collationStatement)
 
-	systemDb, err := g.Open(createDialector(dialect, *systemDbConnStr), options)
+	systemDb, err := openDb(ctx, dialect, *systemDbConnStr, options, nil)
 	if err != nil {
-		return nil
-	}
-	systemDb.Exec(createStatement)
-	sqlDb, err := systemDb.DB()
-	if err == nil {
-		sqlDb.Close()
+		return nil, newDbErrorContext(ctx, ErrCreateFailed, dialect, *dbName, errors.Unwrap(err))
 	}
-	db, err := g.Open(createDialector(dialect, *dbConnStr), options)
+	err = systemDb.WithContext(ctx).Exec(createStatement).Error
+	CloseDb(systemDb)
 	if err != nil {
-		return nil
+		return nil, newDbErrorContext(ctx, ErrCreateFailed, dialect, *dbName, err)
 	}
-	return db
+	return openDb(ctx, dialect, *dbConnStr, options, openOptions)
 }
 
-// getSymbolIndex
-/* Function that searches index of symbol in string from start position (index)
+// createDialector
+/* Function that creates dialector that uses already opened connection pool
  * Parameters:
- *    - str - string where we are searching for a symbol
- *    - symbol - single symbol that we are searching in a string
- *    - startIndex - index of string from what we
- * Returns index of symbol in str otherwise -1
- */
-func getSymbolIndex(str *string, symbol rune, startIndex int) int {
-	strSymbols := []rune(*str)
-	if startIndex < 0 {
-		startIndex = 0
-	}
-	for i := startIndex; i < len(*str); i++ {
-		if strSymbols[i] == symbol {
-			return i
-		}
+ *    - dialect - dialect of database server
+ *    - dbConnStr - connection string that was used to open sqlDb
+ *    - sqlDb - opened connection pool
+ * Return dialector or nil
+ */
+func createDialector(dialect SqlDialect, dbConnStr string, sqlDb *sql.DB) g.Dialector {
+	switch dialect {
+	case Mysql:
+		return mysql.New(mysql.Config{DSN: dbConnStr, Conn: sqlDb})
+	case Mssql:
+		return sqlserver.New(sqlserver.Config{DSN: dbConnStr, Conn: sqlDb})
+	case Postgres:
+		return postgres.New(postgres.Config{DSN: dbConnStr, Conn: sqlDb})
+	case Sqlite:
+		return &sqlite.Dialector{DSN: dbConnStr, Conn: sqlDb}
+	default:
+		return nil
 	}
-	return -1
 }
 
-// createDialector
-/* Function that creates dialector (calls Open of driver)
+// getSqlDriverName
+/* Function that returns database/sql driver name that is using by gorm driver of dialect
  * Parameters:
  *    - dialect - dialect of database server
- *    - dbConnStr -
- * Return dialector or nil
+ * Returns driver name or empty string if dialect is not supported
  */
-func createDialector(dialect SqlDialect, dbConnStr string) g.Dialector {
-	if dialect == Mysql {
-		return mysql.Open(dbConnStr)
-	}
-	if dialect == Mssql {
-		return sqlserver.Open(dbConnStr)
-	}
-	if dialect == Postgres {
-		return postgres.Open(dbConnStr)
+func getSqlDriverName(dialect SqlDialect) string {
+	switch dialect {
+	case Mysql:
+		return "mysql"
+	case Mssql:
+		return "sqlserver"
+	case Postgres:
+		return "pgx"
+	case Sqlite:
+		return sqlite.DriverName
+	default:
+		return ""
 	}
-	//return sqlite.Open(dbConnStr)
-	return nil
 }
 
 func createCollationOption(dialect SqlDialect, collation *Collation) string {
@@ -411,3 +813,53 @@ func createCollationOption(dialect SqlDialect, collation *Collation) string {
 		return ""
 	}
 }
+
+// getSqliteDbFile
+/* Function that extracts database file path from sqlite connection string, connection string could be a plain path
+ * (/tmp/app.db), URI (file:/tmp/app.db?cache=shared) or in-memory database (:memory:, file::memory:?cache=shared or
+ * file:app?mode=memory)
+ * Parameters:
+ *    - connStr - sqlite connection string
+ * Returns path to database file or :memory: for in-memory database
+ */
+func getSqliteDbFile(connStr string) string {
+	cfg := parseSqliteConnStr(connStr)
+	if len(cfg.DbName) == 0 || cfg.DbName == sqliteMemoryDb || cfg.Params["mode"] == "memory" {
+		return sqliteMemoryDb
+	}
+	return cfg.DbName
+}
+
+// checkSqliteDbFile
+/* Function that checks that sqlite database file exists
+ * Parameters:
+ *    - connStr - sqlite connection string
+ * Returns true if file exists or database is in-memory database
+ */
+func checkSqliteDbFile(connStr string) bool {
+	dbFile := getSqliteDbFile(connStr)
+	if dbFile == sqliteMemoryDb {
+		return true
+	}
+	info, err := os.Stat(dbFile)
+	return err == nil && !info.IsDir()
+}
+
+// dropSqliteDbFile
+/* Function that removes sqlite database file, in-memory database disappears with last connection close,
+ * therefore there is nothing to remove
+ * Parameters:
+ *    - dbFile - path to database file
+ * Returns nil if file was removed or does not exist
+ */
+func dropSqliteDbFile(dbFile string) error {
+	dbFile = getSqliteDbFile(dbFile)
+	if dbFile == sqliteMemoryDb {
+		return nil
+	}
+	err := os.Remove(dbFile)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -1,8 +1,14 @@
 package gorm
 
 import (
+	"context"
+	"errors"
 	"github.com/stretchr/testify/assert"
 	"gorm.io/gorm"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
 
 	//"gorm.io/gorm"
 	"testing"
@@ -47,6 +53,12 @@ func TestBuildMssqlConnectionString(t *testing.T) {
 	assert.Equal(t, expectedConnStr, connStr)
 }
 
+func TestBuildSqliteConnectionString(t *testing.T) {
+	connStr := BuildConnectionString(Sqlite, "", 0, "/tmp/custom_app.db", "", "", "")
+	expectedConnStr := "/tmp/custom_app.db"
+	assert.Equal(t, expectedConnStr, connStr)
+}
+
 // test open db (system db without create)
 
 func TestPostgresOpenSystemDb(t *testing.T) {
@@ -105,6 +117,75 @@ func TestMssqlOpenDbWithCreate(t *testing.T) {
 	testOpenDbWithCreateAndCheck(t, connStr, Mssql, &cfg, nil)
 }
 
+func TestSqliteOpenDbWithCreate(t *testing.T) {
+	cfg := gorm.Config{}
+	connStr := BuildConnectionString(Sqlite, "", 0, filepath.Join(t.TempDir(), "sqlite_gwuu_examples.db"), "", "", "")
+	testOpenDbWithCreateAndCheck(t, connStr, Sqlite, &cfg, nil)
+}
+
+func TestSqliteOpenDbWithoutCreate(t *testing.T) {
+	cfg := gorm.Config{}
+	connStr := BuildConnectionString(Sqlite, "", 0, filepath.Join(t.TempDir(), "sqlite_gwuu_missing.db"), "", "", "")
+	db := OpenDb2(Sqlite, connStr, false, true, &cfg, nil)
+	assert.Nil(t, db)
+}
+
+func TestSqliteOpenInMemoryDb(t *testing.T) {
+	cfg := gorm.Config{}
+	db := OpenDb2(Sqlite, "file::memory:?cache=shared", true, true, &cfg, nil)
+	assert.NotNil(t, db)
+	prepareDatabase(db)
+	role := Role{Name: "in_memory"}
+	db.Create(&role)
+	assert.True(t, role.ID > 0)
+	CloseDb(db)
+	assert.True(t, DropDb(Sqlite, "file::memory:?cache=shared", &cfg))
+}
+
+func TestSqliteOpenDb2ContextWhenCancelled(t *testing.T) {
+	cfg := gorm.Config{}
+	connStr := filepath.Join(t.TempDir(), "sqlite_gwuu_cancelled.db")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	db, err := OpenDb2Context(ctx, Sqlite, connStr, true, true, &cfg, nil, nil)
+	assert.Nil(t, db)
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.False(t, CheckDb(Sqlite, connStr, &cfg))
+}
+
+func TestOpenDb2ContextWithDeadlineWhenServerDoesNotRespond(t *testing.T) {
+	cfg := gorm.Config{}
+	// server accepts connections but never responds, therefore startup hangs until deadline
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		conns := make([]net.Conn, 0)
+		defer func() {
+			for _, conn := range conns {
+				_ = conn.Close()
+			}
+		}()
+		for {
+			conn, acceptErr := listener.Accept()
+			if acceptErr != nil {
+				return
+			}
+			conns = append(conns, conn)
+		}
+	}()
+	port := listener.Addr().(*net.TCPAddr).Port
+	connStr := BuildConnectionString(Postgres, "127.0.0.1", port, "pg_gwuu_examples", dbUser, dbPassword, "disable")
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	db, err := OpenDb2Context(ctx, Postgres, connStr, true, true, &cfg, nil, nil)
+	assert.Nil(t, db)
+	assert.True(t, errors.Is(err, ErrOpenFailed))
+	assert.GreaterOrEqual(t, time.Since(start), 500*time.Millisecond)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
 func TestCreateRandomDb(t *testing.T) {
 	cfg := gorm.Config{}
 	db, connStr := CreateRandomDb(Postgres, "127.0.0.1", 5432, dbUser, dbPassword, "disable", &cfg,
@@ -116,6 +197,51 @@ func TestCreateRandomDb(t *testing.T) {
 	DropDb(Postgres, connStr, &cfg)
 }
 
+func TestCreateRandomSqliteDb(t *testing.T) {
+	cfg := gorm.Config{}
+	db, connStr := CreateRandomDb(Sqlite, "", 0, "", "", "", &cfg, nil)
+	assert.NotNil(t, db)
+	assert.Equal(t, os.TempDir(), filepath.Dir(connStr))
+	check := CheckDb(Sqlite, connStr, &cfg)
+	assert.True(t, check)
+	CloseDb(db)
+	assert.True(t, DropDb(Sqlite, connStr, &cfg))
+	_, err := os.Stat(connStr)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestSqliteDatabaseExists(t *testing.T) {
+	dbFile := filepath.Join(t.TempDir(), "sqlite_gwuu_exists.db")
+	exists, err := DatabaseExists(Sqlite, "", dbFile)
+	assert.NoError(t, err)
+	assert.False(t, exists)
+	assert.NoError(t, os.WriteFile(dbFile, []byte{}, 0o600))
+	exists, err = DatabaseExists(Sqlite, "", dbFile)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	exists, err = DatabaseExists(Sqlite, "", sqliteMemoryDb)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+}
+
+func TestDatabaseExistsWithUnsupportedDialect(t *testing.T) {
+	exists, err := DatabaseExists("oracle", "", "app")
+	assert.False(t, exists)
+	assert.True(t, errors.Is(err, ErrUnsupportedDialect))
+}
+
+func TestPostgresDatabaseExists(t *testing.T) {
+	cfg := gorm.Config{}
+	_, connStr := CreateTestDb(t, Postgres, &cfg, nil)
+	systemDbConnStr, dbName := createSystemDbConnStr(Postgres, &connStr)
+	exists, err := DatabaseExists(Postgres, systemDbConnStr, dbName)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	exists, err = DatabaseExists(Postgres, systemDbConnStr, dbName+"_missing")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+}
+
 // ####################################################################################################################
 
 // ########################################### private functions tests ################################################
@@ -165,6 +291,21 @@ func TestCreateMysqlSystemDbConnectionString(t *testing.T) {
 	assert.Equal(t, "mysuperapp", dbName)
 }
 
+func TestCreateSqliteSystemDbConnectionString(t *testing.T) {
+	connStr := "/tmp/custom_app.db"
+	actualSystemConnStr, dbName := createSystemDbConnStr(Sqlite, &connStr)
+	assert.Empty(t, actualSystemConnStr)
+	assert.Equal(t, "/tmp/custom_app.db", dbName)
+
+	connStr = "file:/tmp/custom_app.db?cache=shared&_fk=1"
+	_, dbName = createSystemDbConnStr(Sqlite, &connStr)
+	assert.Equal(t, "/tmp/custom_app.db", dbName)
+
+	connStr = "file:custom_app?mode=memory&cache=shared"
+	_, dbName = createSystemDbConnStr(Sqlite, &connStr)
+	assert.Equal(t, ":memory:", dbName)
+}
+
 // ####################################################################################################################
 
 // ################################################# internal functions ###############################################
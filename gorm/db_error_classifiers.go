@@ -0,0 +1,303 @@
+package gorm
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	mssql "github.com/denisenkom/go-mssqldb"
+	driverMysql "github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgconn"
+	"regexp"
+	"strings"
+)
+
+// Sentinel errors of constraint violations, they are kinds of ConstraintViolation
+var (
+	ErrUniqueViolation     = errors.New("unique constraint violation")
+	ErrForeignKeyViolation = errors.New("foreign key constraint violation")
+	ErrNotNullViolation    = errors.New("not null constraint violation")
+	ErrCheckViolation      = errors.New("check constraint violation")
+)
+
+// Patterns of driver messages that contain names of violated constraint, table and column (Mysql, Mssql and Sqlite
+// errors have no separate fields for them)
+var (
+	pgKeyColumnsRegexp           = regexp.MustCompile(`^Key \(([^)]+)\)=`)
+	mysqlDuplicateKeyRegexp      = regexp.MustCompile(`for key '([^']+)'`)
+	mysqlForeignKeyRegexp        = regexp.MustCompile("`([^`]+)`, CONSTRAINT `([^`]+)` FOREIGN KEY \\(`([^`]+)`")
+	mysqlColumnRegexp            = regexp.MustCompile(`^(?:Column|Field) '([^']+)'`)
+	mysqlCheckRegexp             = regexp.MustCompile(`^Check constraint '([^']+)'`)
+	mssqlConstraintRegexp        = regexp.MustCompile(`constraint ['"]([^'"]+)['"]`)
+	mssqlObjectRegexp            = regexp.MustCompile(`object '([^']+)'`)
+	mssqlUniqueIndexRegexp       = regexp.MustCompile(`unique index '([^']+)'`)
+	mssqlConflictRegexp          = regexp.MustCompile(`table "([^"]+)"(?:, column '([^']+)')?`)
+	mssqlNullColumnRegexp        = regexp.MustCompile(`column '([^']+)', table '([^']+)'`)
+	sqliteConstraintColumnRegexp = regexp.MustCompile(`constraint failed: ([^.\s]+)\.(.+)$`)
+	sqliteCheckRegexp            = regexp.MustCompile(`CHECK constraint failed: (\S+)`)
+)
+
+// ConstraintViolation is a recognized constraint violation, names are empty if driver does not report them
+type ConstraintViolation struct {
+	// Kind is ErrUniqueViolation, ErrForeignKeyViolation, ErrNotNullViolation or ErrCheckViolation
+	Kind error
+	// Constraint is a constraint (or unique index) name
+	Constraint string
+	// Table is a table name, for Mssql foreign key violation it is a referenced table
+	Table string
+	// Column is a column name (comma separated column names for composite keys)
+	Column string
+	// Err is an original driver error
+	Err error
+}
+
+// GetConstraintViolation
+/* Function that recognizes constraint violation error of pgx, go-sql-driver/mysql, go-mssqldb and go-sqlite3 drivers
+ * and extracts constraint, table and column names where the driver exposes them (Postgres error fields or message)
+ * Parameters:
+ *    - err - error returned by gorm or driver (wrapped errors are unwrapped)
+ * Returns tuple of violation and true if error is a constraint violation
+ */
+func GetConstraintViolation(err error) (*ConstraintViolation, bool) {
+	if err == nil {
+		return nil, false
+	}
+	violation := ConstraintViolation{Err: err}
+	var pgErr *pgconn.PgError
+	var mysqlErr *driverMysql.MySQLError
+	var mssqlErr mssql.Error
+	switch {
+	case errors.As(err, &pgErr):
+		violation.Kind = getPostgresConstraintKind(pgErr.Code)
+		violation.Constraint = pgErr.ConstraintName
+		violation.Table = pgErr.TableName
+		violation.Column = pgErr.ColumnName
+		if match := pgKeyColumnsRegexp.FindStringSubmatch(pgErr.Detail); len(violation.Column) == 0 && match != nil {
+			violation.Column = match[1]
+		}
+	case errors.As(err, &mysqlErr):
+		fillMysqlConstraintViolation(&violation, mysqlErr)
+	case errors.As(err, &mssqlErr):
+		fillMssqlConstraintViolation(&violation, mssqlErr)
+	default:
+		fillSqliteConstraintViolation(&violation, err)
+	}
+	if violation.Kind == nil {
+		return nil, false
+	}
+	return &violation, true
+}
+
+// IsUniqueViolation
+/* Function that checks whether error is a unique (or primary key) constraint violation (Postgres 23505, Mysql 1062,
+ * Mssql 2627 / 2601, Sqlite SQLITE_CONSTRAINT_UNIQUE / SQLITE_CONSTRAINT_PRIMARYKEY)
+ * Parameters:
+ *    - err - error returned by gorm or driver
+ * Returns true if error is a unique violation
+ */
+func IsUniqueViolation(err error) bool {
+	return isConstraintViolation(err, ErrUniqueViolation)
+}
+
+// IsForeignKeyViolation
+/* Function that checks whether error is a foreign key constraint violation (Postgres 23503, Mysql 1451 / 1452,
+ * Mssql 547 with FOREIGN KEY or REFERENCE constraint, Sqlite SQLITE_CONSTRAINT_FOREIGNKEY)
+ * Parameters:
+ *    - err - error returned by gorm or driver
+ * Returns true if error is a foreign key violation
+ */
+func IsForeignKeyViolation(err error) bool {
+	return isConstraintViolation(err, ErrForeignKeyViolation)
+}
+
+// IsNotNullViolation
+/* Function that checks whether error is a not null constraint violation (Postgres 23502, Mysql 1048 / 1364, Mssql 515,
+ * Sqlite SQLITE_CONSTRAINT_NOTNULL)
+ * Parameters:
+ *    - err - error returned by gorm or driver
+ * Returns true if error is a not null violation
+ */
+func IsNotNullViolation(err error) bool {
+	return isConstraintViolation(err, ErrNotNullViolation)
+}
+
+// IsCheckViolation
+/* Function that checks whether error is a check constraint violation (Postgres 23514, Mysql 3819, Mssql 547 with CHECK
+ * constraint, Sqlite SQLITE_CONSTRAINT_CHECK)
+ * Parameters:
+ *    - err - error returned by gorm or driver
+ * Returns true if error is a check violation
+ */
+func IsCheckViolation(err error) bool {
+	return isConstraintViolation(err, ErrCheckViolation)
+}
+
+// IsDeadlock
+/* Function that checks whether transaction was chosen as a deadlock victim (Postgres 40P01, Mysql 1213, Mssql 1205),
+ * such transaction could be repeated (see WithTransaction)
+ * Parameters:
+ *    - err - error returned by gorm or driver
+ * Returns true if error is a deadlock
+ */
+func IsDeadlock(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "40P01"
+	}
+	var mysqlErr *driverMysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == 1213
+	}
+	var mssqlErr mssql.Error
+	if errors.As(err, &mssqlErr) {
+		return mssqlErr.Number == 1205
+	}
+	return false
+}
+
+// IsConnectionError
+/* Function that checks whether error means that database is not available or connection was lost (network errors,
+ * Postgres 08xxx and server shutdown, Mysql lost connection, see IsRetryableDbError) or connection is closed
+ * Parameters:
+ *    - err - error returned by gorm or driver
+ * Returns true if error is a connection error
+ */
+func IsConnectionError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) {
+		return true
+	}
+	return isTransientConnectionError(err)
+}
+
+// IsPermissionDenied
+/* Function that checks whether error is a permission (privileges) error (Postgres 42501, Mysql 1044 / 1142 / 1227,
+ * Mssql 229 / 230 / 262, Sqlite SQLITE_PERM / SQLITE_AUTH / SQLITE_READONLY) or DbError with ErrPermissionDenied reason
+ * Parameters:
+ *    - err - error returned by gorm, driver or gwuu function
+ * Returns true if error is a permission error
+ */
+func IsPermissionDenied(err error) bool {
+	if err == nil {
+		return false
+	}
+	return errors.Is(err, ErrPermissionDenied) || classifyDbError(err) == ErrPermissionDenied
+}
+
+// isConstraintViolation
+/* Function that checks whether error is a constraint violation of kind
+ */
+func isConstraintViolation(err error, kind error) bool {
+	violation, ok := GetConstraintViolation(err)
+	return ok && violation.Kind == kind
+}
+
+// getPostgresConstraintKind
+/* Function that converts Postgres SQLSTATE of integrity constraint violation to violation kind
+ */
+func getPostgresConstraintKind(code string) error {
+	switch code {
+	case "23505":
+		return ErrUniqueViolation
+	case "23503":
+		return ErrForeignKeyViolation
+	case "23502":
+		return ErrNotNullViolation
+	case "23514":
+		return ErrCheckViolation
+	default:
+		return nil
+	}
+}
+
+// fillMysqlConstraintViolation
+/* Function that recognizes Mysql constraint violation by error number and extracts names from message:
+ *    - 1062 Duplicate entry 'x' for key 'users.idx_email' (table prefix since Mysql 8)
+ *    - 1451 / 1452 ... a foreign key constraint fails (`db`.`users`, CONSTRAINT `fk` FOREIGN KEY (`role_id`) ...
+ *    - 1048 Column 'name' cannot be null, 1364 Field 'name' doesn't have a default value
+ *    - 3819 Check constraint 'chk_age' is violated.
+ */
+func fillMysqlConstraintViolation(violation *ConstraintViolation, mysqlErr *driverMysql.MySQLError) {
+	switch mysqlErr.Number {
+	case 1062:
+		violation.Kind = ErrUniqueViolation
+		if match := mysqlDuplicateKeyRegexp.FindStringSubmatch(mysqlErr.Message); match != nil {
+			violation.Constraint = match[1]
+			if separator := strings.LastIndex(match[1], "."); separator >= 0 {
+				violation.Table = match[1][:separator]
+				violation.Constraint = match[1][separator+1:]
+			}
+		}
+	case 1451, 1452:
+		violation.Kind = ErrForeignKeyViolation
+		if match := mysqlForeignKeyRegexp.FindStringSubmatch(mysqlErr.Message); match != nil {
+			violation.Table = match[1]
+			violation.Constraint = match[2]
+			violation.Column = match[3]
+		}
+	case 1048, 1364:
+		violation.Kind = ErrNotNullViolation
+		if match := mysqlColumnRegexp.FindStringSubmatch(mysqlErr.Message); match != nil {
+			violation.Column = match[1]
+		}
+	case 3819:
+		violation.Kind = ErrCheckViolation
+		if match := mysqlCheckRegexp.FindStringSubmatch(mysqlErr.Message); match != nil {
+			violation.Constraint = match[1]
+		}
+	}
+}
+
+// fillMssqlConstraintViolation
+/* Function that recognizes Mssql constraint violation by error number and extracts names from message:
+ *    - 2627 Violation of UNIQUE KEY constraint 'uq'. Cannot insert duplicate key in object 'dbo.users'...
+ *    - 2601 Cannot insert duplicate key row in object 'dbo.users' with unique index 'idx_email'...
+ *    - 547 The INSERT statement conflicted with the FOREIGN KEY (REFERENCE or CHECK) constraint "fk". The conflict
+ *      occurred in database "db", table "dbo.roles", column 'id'.
+ *    - 515 Cannot insert the value NULL into column 'name', table 'db.dbo.users'; column does not allow nulls...
+ * Schema and database prefixes are removed from table names
+ */
+func fillMssqlConstraintViolation(violation *ConstraintViolation, mssqlErr mssql.Error) {
+	message := mssqlErr.Message
+	switch mssqlErr.Number {
+	case 2627, 2601:
+		violation.Kind = ErrUniqueViolation
+		if match := mssqlObjectRegexp.FindStringSubmatch(message); match != nil {
+			violation.Table = trimObjectQualifier(match[1])
+		}
+		if match := mssqlConstraintRegexp.FindStringSubmatch(message); match != nil {
+			violation.Constraint = match[1]
+		} else if match = mssqlUniqueIndexRegexp.FindStringSubmatch(message); match != nil {
+			violation.Constraint = match[1]
+		}
+	case 547:
+		if strings.Contains(message, "CHECK constraint") {
+			violation.Kind = ErrCheckViolation
+		} else if strings.Contains(message, "FOREIGN KEY constraint") || strings.Contains(message, "REFERENCE constraint") {
+			violation.Kind = ErrForeignKeyViolation
+		} else {
+			return
+		}
+		if match := mssqlConstraintRegexp.FindStringSubmatch(message); match != nil {
+			violation.Constraint = match[1]
+		}
+		if match := mssqlConflictRegexp.FindStringSubmatch(message); match != nil {
+			violation.Table = trimObjectQualifier(match[1])
+			violation.Column = match[2]
+		}
+	case 515:
+		violation.Kind = ErrNotNullViolation
+		if match := mssqlNullColumnRegexp.FindStringSubmatch(message); match != nil {
+			violation.Column = match[1]
+			violation.Table = trimObjectQualifier(match[2])
+		}
+	}
+}
+
+// trimObjectQualifier
+/* Function that removes database and schema from qualified object name (db.dbo.users -> users)
+ */
+func trimObjectQualifier(name string) string {
+	return name[strings.LastIndex(name, ".")+1:]
+}
@@ -0,0 +1,144 @@
+package gorm
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	mssql "github.com/denisenkom/go-mssqldb"
+	driverMysql "github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgconn"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"testing"
+)
+
+// Account is a model with unique, not null and check constraints
+type Account struct {
+	ID    uint    `gorm:"primaryKey"`
+	Email string  `gorm:"uniqueIndex;not null"`
+	Name  *string `gorm:"not null"`
+	Age   int     `gorm:"check:chk_accounts_age,age >= 0"`
+}
+
+func TestGetPostgresConstraintViolation(t *testing.T) {
+	err := fmt.Errorf("create user: %w", &pgconn.PgError{Code: "23505", ConstraintName: "idx_users_email",
+		TableName: "users", Detail: "Key (email)=(john@example.com) already exists."})
+	violation, ok := GetConstraintViolation(err)
+	assert.True(t, ok)
+	assert.Equal(t, ErrUniqueViolation, violation.Kind)
+	assert.Equal(t, "idx_users_email", violation.Constraint)
+	assert.Equal(t, "users", violation.Table)
+	assert.Equal(t, "email", violation.Column)
+	assert.True(t, IsUniqueViolation(err))
+	assert.False(t, IsForeignKeyViolation(err))
+
+	assert.True(t, IsForeignKeyViolation(&pgconn.PgError{Code: "23503"}))
+	assert.True(t, IsNotNullViolation(&pgconn.PgError{Code: "23502", ColumnName: "name"}))
+	assert.True(t, IsCheckViolation(&pgconn.PgError{Code: "23514"}))
+	assert.True(t, IsDeadlock(&pgconn.PgError{Code: "40P01"}))
+	assert.True(t, IsPermissionDenied(&pgconn.PgError{Code: "42501"}))
+	_, ok = GetConstraintViolation(&pgconn.PgError{Code: "40001"})
+	assert.False(t, ok)
+}
+
+func TestGetMysqlConstraintViolation(t *testing.T) {
+	violation, ok := GetConstraintViolation(&driverMysql.MySQLError{Number: 1062,
+		Message: "Duplicate entry 'john@example.com' for key 'users.idx_users_email'"})
+	assert.True(t, ok)
+	assert.Equal(t, ErrUniqueViolation, violation.Kind)
+	assert.Equal(t, "idx_users_email", violation.Constraint)
+	assert.Equal(t, "users", violation.Table)
+
+	violation, ok = GetConstraintViolation(&driverMysql.MySQLError{Number: 1452, Message: "Cannot add or update a " +
+		"child row: a foreign key constraint fails (`app`.`users`, CONSTRAINT `fk_users_role` FOREIGN KEY (`role_id`) " +
+		"REFERENCES `roles` (`id`))"})
+	assert.True(t, ok)
+	assert.Equal(t, ErrForeignKeyViolation, violation.Kind)
+	assert.Equal(t, "fk_users_role", violation.Constraint)
+	assert.Equal(t, "users", violation.Table)
+	assert.Equal(t, "role_id", violation.Column)
+
+	violation, ok = GetConstraintViolation(&driverMysql.MySQLError{Number: 1048, Message: "Column 'name' cannot be null"})
+	assert.True(t, ok)
+	assert.Equal(t, ErrNotNullViolation, violation.Kind)
+	assert.Equal(t, "name", violation.Column)
+
+	assert.True(t, IsCheckViolation(&driverMysql.MySQLError{Number: 3819, Message: "Check constraint 'chk_age' is violated."}))
+	assert.True(t, IsDeadlock(&driverMysql.MySQLError{Number: 1213}))
+	assert.False(t, IsUniqueViolation(&driverMysql.MySQLError{Number: 1213}))
+}
+
+func TestGetMssqlConstraintViolation(t *testing.T) {
+	violation, ok := GetConstraintViolation(mssql.Error{Number: 2627, Message: "Violation of UNIQUE KEY constraint " +
+		"'uq_users_email'. Cannot insert duplicate key in object 'dbo.users'. The duplicate key value is (john)."})
+	assert.True(t, ok)
+	assert.Equal(t, ErrUniqueViolation, violation.Kind)
+	assert.Equal(t, "uq_users_email", violation.Constraint)
+	assert.Equal(t, "users", violation.Table)
+
+	violation, ok = GetConstraintViolation(mssql.Error{Number: 2601, Message: "Cannot insert duplicate key row in " +
+		"object 'dbo.users' with unique index 'idx_users_email'. The duplicate key value is (john)."})
+	assert.True(t, ok)
+	assert.Equal(t, "idx_users_email", violation.Constraint)
+	assert.Equal(t, "users", violation.Table)
+
+	violation, ok = GetConstraintViolation(mssql.Error{Number: 547, Message: "The INSERT statement conflicted with " +
+		"the FOREIGN KEY constraint \"fk_users_role\". The conflict occurred in database \"app\", table \"dbo.roles\", " +
+		"column 'id'."})
+	assert.True(t, ok)
+	assert.Equal(t, ErrForeignKeyViolation, violation.Kind)
+	assert.Equal(t, "fk_users_role", violation.Constraint)
+	assert.Equal(t, "roles", violation.Table)
+	assert.Equal(t, "id", violation.Column)
+
+	violation, ok = GetConstraintViolation(mssql.Error{Number: 515, Message: "Cannot insert the value NULL into " +
+		"column 'name', table 'app.dbo.users'; column does not allow nulls. INSERT fails."})
+	assert.True(t, ok)
+	assert.Equal(t, ErrNotNullViolation, violation.Kind)
+	assert.Equal(t, "name", violation.Column)
+	assert.Equal(t, "users", violation.Table)
+
+	assert.True(t, IsCheckViolation(mssql.Error{Number: 547, Message: "The INSERT statement conflicted with the " +
+		"CHECK constraint \"chk_age\"."}))
+	assert.True(t, IsDeadlock(mssql.Error{Number: 1205}))
+	assert.True(t, IsPermissionDenied(mssql.Error{Number: 229}))
+}
+
+func TestGetSqliteConstraintViolation(t *testing.T) {
+	db, _ := CreateTestDb(t, Sqlite, &gorm.Config{}, nil)
+	assert.NoError(t, db.AutoMigrate(&Account{}))
+	name := "John"
+	assert.NoError(t, db.Create(&Account{Email: "john@example.com", Name: &name}).Error)
+
+	err := db.Create(&Account{Email: "john@example.com", Name: &name}).Error
+	violation, ok := GetConstraintViolation(err)
+	assert.True(t, ok)
+	assert.Equal(t, ErrUniqueViolation, violation.Kind)
+	assert.Equal(t, "accounts", violation.Table)
+	assert.Equal(t, "email", violation.Column)
+	assert.Equal(t, err, violation.Err)
+
+	err = db.Create(&Account{Email: "jane@example.com"}).Error
+	violation, ok = GetConstraintViolation(err)
+	assert.True(t, ok)
+	assert.Equal(t, ErrNotNullViolation, violation.Kind)
+	assert.Equal(t, "name", violation.Column)
+
+	err = db.Create(&Account{Email: "jane@example.com", Name: &name, Age: -1}).Error
+	violation, ok = GetConstraintViolation(err)
+	assert.True(t, ok)
+	assert.Equal(t, ErrCheckViolation, violation.Kind)
+	assert.Equal(t, "chk_accounts_age", violation.Constraint)
+	assert.False(t, IsUniqueViolation(err))
+}
+
+func TestIsConnectionError(t *testing.T) {
+	assert.True(t, IsConnectionError(driver.ErrBadConn))
+	assert.True(t, IsConnectionError(fmt.Errorf("query: %w", sql.ErrConnDone)))
+	assert.True(t, IsConnectionError(&pgconn.PgError{Code: "08006"}))
+	assert.False(t, IsConnectionError(&pgconn.PgError{Code: "23505"}))
+	assert.False(t, IsConnectionError(nil))
+	assert.False(t, IsPermissionDenied(nil))
+	_, ok := GetConstraintViolation(nil)
+	assert.False(t, ok)
+}
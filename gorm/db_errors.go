@@ -0,0 +1,210 @@
+package gorm
+
+import (
+	"context"
+	"errors"
+	mssql "github.com/denisenkom/go-mssqldb"
+	driverMysql "github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgconn"
+	"github.com/wissance/stringFormatter"
+	"strings"
+)
+
+// Sentinel errors that are returning (wrapped in DbError) by functions with WithError suffix, they should be checked
+// with errors.Is, driver error that caused them could be extracted with errors.As (i.e. *pgconn.PgError)
+var (
+	ErrUnsupportedDialect   = errors.New("unsupported sql dialect")
+	ErrInvalidConnStr       = errors.New("invalid connection string")
+	ErrInvalidIdentifier    = errors.New("invalid identifier")
+	ErrDatabaseNotFound     = errors.New("database not found")
+	ErrDatabaseExists       = errors.New("database already exists")
+	ErrAuthenticationFailed = errors.New("database authentication failed")
+	ErrPermissionDenied     = errors.New("database permission denied")
+	ErrCollationMismatch    = errors.New("database collation mismatch")
+	ErrSchemaNotFound       = errors.New("schema not found")
+	ErrSchemaExists         = errors.New("schema already exists")
+	ErrSchemaNotEmpty       = errors.New("schema is not empty")
+	ErrOpenFailed           = errors.New("database open failed")
+	ErrCreateFailed         = errors.New("database create failed")
+	ErrDropFailed           = errors.New("database drop failed")
+	ErrCloseFailed          = errors.New("database close failed")
+	ErrSchemaCreateFailed   = errors.New("schema create failed")
+	ErrSchemaDropFailed     = errors.New("schema drop failed")
+	ErrResetFailed          = errors.New("database reset failed")
+	ErrHealthCheckFailed    = errors.New("database health check failed")
+)
+
+// DbError is an error of database or schema lifecycle operation (open, create, check, drop or close)
+/* DbError matches (errors.Is) two sentinel errors:
+ *    - Kind - what operation failed (ErrOpenFailed, ErrCreateFailed, ErrDropFailed, ErrSchemaCreateFailed ...)
+ *    - Reason - why it failed if driver error was recognized (ErrDatabaseNotFound, ErrAuthenticationFailed ...)
+ * Err is an original driver error, it is returned by Unwrap
+ */
+type DbError struct {
+	Dialect SqlDialect
+	DbName  string
+	// Schema is set only by schema operations
+	Schema string
+	Kind   error
+	Reason error
+	Err    error
+}
+
+func (e *DbError) Error() string {
+	location := stringFormatter.Format("database: \"{0}\"", e.DbName)
+	if len(e.Schema) > 0 {
+		location = location + stringFormatter.Format(", schema: \"{0}\"", e.Schema)
+	}
+	msg := stringFormatter.Format("{0} ({1}, {2})", e.Kind.Error(), string(e.Dialect), location)
+	if e.Reason != nil && e.Reason != e.Kind {
+		msg = msg + ": " + e.Reason.Error()
+	}
+	if e.Err != nil {
+		msg = msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *DbError) Unwrap() error {
+	return e.Err
+}
+
+func (e *DbError) Is(target error) bool {
+	return target != nil && (target == e.Kind || target == e.Reason)
+}
+
+// wrappedError is an error with message that matches (errors.Is) sentinel Kind and unwraps to cause Err, both of them
+// could be nil
+type wrappedError struct {
+	Kind error
+	Msg  string
+	Err  error
+}
+
+func (e *wrappedError) Error() string {
+	parts := make([]string, 0, 3)
+	if e.Kind != nil {
+		parts = append(parts, e.Kind.Error())
+	}
+	if len(e.Msg) > 0 {
+		parts = append(parts, e.Msg)
+	}
+	if e.Err != nil {
+		parts = append(parts, e.Err.Error())
+	}
+	return strings.Join(parts, ": ")
+}
+
+func (e *wrappedError) Unwrap() error {
+	return e.Err
+}
+
+func (e *wrappedError) Is(target error) bool {
+	return target != nil && target == e.Kind
+}
+
+// newWrappedError
+/* Function that creates error with message formatted by stringFormatter that matches sentinel error and wraps cause
+ * Parameters:
+ *    - kind - sentinel error (i.e. ErrInvalidFilter), could be nil
+ *    - err - cause error, could be nil
+ *    - template - message template ({0}, {1} ... placeholders), could be empty
+ *    - args - template arguments
+ * Returns error
+ */
+func newWrappedError(kind error, err error, template string, args ...interface{}) error {
+	return &wrappedError{Kind: kind, Msg: stringFormatter.Format(template, args...), Err: err}
+}
+
+// newDbError
+/* Function that creates DbError and classifies driver error
+ * Parameters:
+ *    - kind - sentinel error of failed operation
+ *    - dialect - string that represent using db driver inside gorm (see enum above)
+ *    - dbName - database name
+ *    - err - driver error (could be nil)
+ * Returns error
+ */
+func newDbError(kind error, dialect SqlDialect, dbName string, err error) error {
+	return &DbError{Dialect: dialect, DbName: dbName, Kind: kind, Reason: classifyDbError(err), Err: err}
+}
+
+// newDbErrorContext
+/* Function that creates DbError like newDbError does, but if ctx was cancelled or its deadline exceeded, ctx error
+ * is used as a Reason, therefore errors.Is(err, context.DeadlineExceeded) could be used
+ * Parameters:
+ *    - ctx - context of failed operation
+ *    - other parameters are the same as in newDbError
+ * Returns error
+ */
+func newDbErrorContext(ctx context.Context, kind error, dialect SqlDialect, dbName string, err error) error {
+	dbErr := &DbError{Dialect: dialect, DbName: dbName, Kind: kind, Reason: classifyDbError(err), Err: err}
+	if dbErr.Reason == nil && ctx.Err() != nil {
+		dbErr.Reason = ctx.Err()
+	}
+	return dbErr
+}
+
+// classifyDbError
+/* Function that recognizes driver (pgx, go-sql-driver/mysql, go-mssqldb, go-sqlite3) error
+ * Parameters:
+ *    - err - driver error
+ * Returns one of sentinel errors (ErrDatabaseNotFound, ErrDatabaseExists, ErrAuthenticationFailed, ErrPermissionDenied)
+ * or nil if error was not recognized
+ */
+func classifyDbError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "3D000":
+			return ErrDatabaseNotFound
+		case "42P04":
+			return ErrDatabaseExists
+		case "28000", "28P01":
+			return ErrAuthenticationFailed
+		case "42501":
+			return ErrPermissionDenied
+		case "3F000":
+			return ErrSchemaNotFound
+		case "42P06":
+			return ErrSchemaExists
+		case "2BP01":
+			return ErrSchemaNotEmpty
+		}
+		return nil
+	}
+	var mysqlErr *driverMysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		switch mysqlErr.Number {
+		case 1049, 1008:
+			return ErrDatabaseNotFound
+		case 1007:
+			return ErrDatabaseExists
+		case 1045:
+			return ErrAuthenticationFailed
+		case 1044, 1142, 1227:
+			return ErrPermissionDenied
+		}
+		return nil
+	}
+	var mssqlErr mssql.Error
+	if errors.As(err, &mssqlErr) {
+		switch mssqlErr.Number {
+		case 4060, 3701:
+			return ErrDatabaseNotFound
+		case 1801:
+			return ErrDatabaseExists
+		case 18456:
+			return ErrAuthenticationFailed
+		case 229, 230, 262:
+			return ErrPermissionDenied
+		case 3729:
+			return ErrSchemaNotEmpty
+		}
+		return nil
+	}
+	return classifySqliteError(err)
+}
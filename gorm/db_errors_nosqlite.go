@@ -0,0 +1,18 @@
+//go:build !cgo
+
+package gorm
+
+// classifySqliteError
+/* Function that does nothing in builds without cgo: go-sqlite3 driver could not be used without cgo, therefore there
+ * are no Sqlite errors to recognize
+ * Returns nil
+ */
+func classifySqliteError(err error) error {
+	return nil
+}
+
+// fillSqliteConstraintViolation
+/* Function that does nothing in builds without cgo (there are no Sqlite errors)
+ */
+func fillSqliteConstraintViolation(violation *ConstraintViolation, err error) {
+}
@@ -0,0 +1,62 @@
+//go:build cgo
+
+package gorm
+
+import (
+	"errors"
+	"github.com/mattn/go-sqlite3"
+	"strings"
+)
+
+// classifySqliteError
+/* Function that recognizes go-sqlite3 error by its code, go-sqlite3 errors exist only in cgo builds (see
+ * db_errors_nosqlite.go)
+ * Parameters:
+ *    - err - driver error
+ * Returns sentinel error or nil if error is not a recognized Sqlite error
+ */
+func classifySqliteError(err error) error {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		switch sqliteErr.Code {
+		case sqlite3.ErrCantOpen:
+			return ErrDatabaseNotFound
+		case sqlite3.ErrPerm, sqlite3.ErrAuth, sqlite3.ErrReadonly:
+			return ErrPermissionDenied
+		}
+	}
+	return nil
+}
+
+// fillSqliteConstraintViolation
+/* Function that recognizes Sqlite constraint violation by extended code and extracts names from message
+ * (UNIQUE constraint failed: users.email, NOT NULL constraint failed: users.name, CHECK constraint failed: chk_age),
+ * foreign key violation message has no names
+ */
+func fillSqliteConstraintViolation(violation *ConstraintViolation, err error) {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return
+	}
+	switch sqliteErr.ExtendedCode {
+	case sqlite3.ErrConstraintUnique, sqlite3.ErrConstraintPrimaryKey:
+		violation.Kind = ErrUniqueViolation
+	case sqlite3.ErrConstraintForeignKey:
+		violation.Kind = ErrForeignKeyViolation
+		return
+	case sqlite3.ErrConstraintNotNull:
+		violation.Kind = ErrNotNullViolation
+	case sqlite3.ErrConstraintCheck:
+		violation.Kind = ErrCheckViolation
+		if match := sqliteCheckRegexp.FindStringSubmatch(sqliteErr.Error()); match != nil {
+			violation.Constraint = match[1]
+		}
+		return
+	default:
+		return
+	}
+	if match := sqliteConstraintColumnRegexp.FindStringSubmatch(sqliteErr.Error()); match != nil {
+		violation.Table = match[1]
+		violation.Column = strings.ReplaceAll(match[2], ", "+match[1]+".", ",")
+	}
+}
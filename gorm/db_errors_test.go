@@ -0,0 +1,89 @@
+package gorm
+
+import (
+	"errors"
+	mssql "github.com/denisenkom/go-mssqldb"
+	driverMysql "github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgconn"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"path/filepath"
+	"testing"
+)
+
+func TestClassifyDriverErrors(t *testing.T) {
+	assert.Nil(t, classifyDbError(nil))
+	assert.Nil(t, classifyDbError(errors.New("some error")))
+	// postgres
+	assert.Equal(t, ErrDatabaseNotFound, classifyDbError(&pgconn.PgError{Code: "3D000"}))
+	assert.Equal(t, ErrAuthenticationFailed, classifyDbError(&pgconn.PgError{Code: "28P01"}))
+	assert.Equal(t, ErrPermissionDenied, classifyDbError(&pgconn.PgError{Code: "42501"}))
+	assert.Equal(t, ErrDatabaseExists, classifyDbError(&pgconn.PgError{Code: "42P04"}))
+	// mysql
+	assert.Equal(t, ErrDatabaseNotFound, classifyDbError(&driverMysql.MySQLError{Number: 1049}))
+	assert.Equal(t, ErrAuthenticationFailed, classifyDbError(&driverMysql.MySQLError{Number: 1045}))
+	assert.Equal(t, ErrPermissionDenied, classifyDbError(&driverMysql.MySQLError{Number: 1044}))
+	// mssql
+	assert.Equal(t, ErrDatabaseNotFound, classifyDbError(mssql.Error{Number: 4060}))
+	assert.Equal(t, ErrAuthenticationFailed, classifyDbError(mssql.Error{Number: 18456}))
+	assert.Equal(t, ErrPermissionDenied, classifyDbError(mssql.Error{Number: 262}))
+}
+
+func TestDbErrorIsAndAs(t *testing.T) {
+	driverErr := &pgconn.PgError{Code: "42501", Message: "permission denied to create database"}
+	err := newDbError(ErrCreateFailed, Postgres, "gwuu_app", driverErr)
+	assert.True(t, errors.Is(err, ErrCreateFailed))
+	assert.True(t, errors.Is(err, ErrPermissionDenied))
+	assert.False(t, errors.Is(err, ErrDropFailed))
+	var pgErr *pgconn.PgError
+	assert.True(t, errors.As(err, &pgErr))
+	assert.Equal(t, "42501", pgErr.Code)
+	var dbErr *DbError
+	assert.True(t, errors.As(err, &dbErr))
+	assert.Equal(t, "gwuu_app", dbErr.DbName)
+}
+
+func TestOpenDb2WithErrorWhenSqliteDbNotFound(t *testing.T) {
+	cfg := gorm.Config{}
+	connStr := filepath.Join(t.TempDir(), "sqlite_gwuu_missing.db")
+	db, err := OpenDb2WithError(Sqlite, connStr, false, true, &cfg, nil)
+	assert.Nil(t, db)
+	assert.True(t, errors.Is(err, ErrOpenFailed))
+	assert.True(t, errors.Is(err, ErrDatabaseNotFound))
+
+	exists, err := CheckDbWithError(Sqlite, connStr, &cfg)
+	assert.False(t, exists)
+	assert.NoError(t, err)
+}
+
+func TestOpenDb2WithErrorWhenDialectIsUnsupported(t *testing.T) {
+	cfg := gorm.Config{}
+	db, err := OpenDb2WithError("oracle", "oracle://localhost:1521/app", false, false, &cfg, nil)
+	assert.Nil(t, db)
+	assert.True(t, errors.Is(err, ErrUnsupportedDialect))
+}
+
+func TestSqliteLifecycleWithError(t *testing.T) {
+	cfg := gorm.Config{}
+	db, connStr, err := CreateRandomDbWithError(Sqlite, "", 0, "", "", "", &cfg, nil)
+	assert.NoError(t, err)
+	assert.NotNil(t, db)
+	exists, err := CheckDbWithError(Sqlite, connStr, &cfg)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.NoError(t, CloseDbWithError(db))
+	assert.NoError(t, DropDbWithError(Sqlite, connStr, &cfg))
+	exists, err = CheckDbWithError(Sqlite, connStr, &cfg)
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	err = CloseDbWithError(nil)
+	assert.True(t, errors.Is(err, ErrCloseFailed))
+}
+
+func TestDropDbWithErrorWhenConnStrIsInvalid(t *testing.T) {
+	cfg := gorm.Config{}
+	err := DropDbWithError(Postgres, "host=localhost port=5432 user=root", &cfg)
+	assert.True(t, errors.Is(err, ErrDropFailed))
+	assert.True(t, errors.Is(err, ErrInvalidConnStr))
+}
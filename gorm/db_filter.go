@@ -0,0 +1,433 @@
+package gorm
+
+import (
+	"errors"
+	"github.com/wissance/stringFormatter"
+	g "gorm.io/gorm"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidFilter is an error of filter or sort expression parsing (syntax error, unknown field or invalid value)
+var ErrInvalidFilter = errors.New("invalid filter")
+
+// FilterType is a type of filter field, values are converted to it before binding
+type FilterType int
+
+const (
+	FilterString FilterType = iota
+	FilterInt
+	FilterFloat
+	FilterBool
+	FilterTime
+)
+
+// filterReservedChars could not be used in unquoted values
+const filterReservedChars = "\"'();,=!~<> \t\r\n"
+
+// filterLikeEscape is an escape character of LIKE patterns that has no special meaning in any dialect
+const filterLikeEscape = "!"
+
+// FilterField is a field that is allowed in filter and sort expressions
+type FilterField struct {
+	// Column is a column name, it could be qualified with table name (users.name)
+	Column string
+	// Type is a type that values are converted to
+	Type FilterType
+}
+
+// filterOperators maps comparison operators to sql operators, == and != with wildcards become LIKE and NOT LIKE
+var filterOperators = map[string]string{
+	"==":       "=",
+	"!=":       "<>",
+	"=lt=":     "<",
+	"<":        "<",
+	"=le=":     "<=",
+	"<=":       "<=",
+	"=gt=":     ">",
+	">":        ">",
+	"=ge=":     ">=",
+	">=":       ">=",
+	"=in=":     "IN",
+	"=out=":    "NOT IN",
+	"=isnull=": "IS NULL",
+}
+
+// filterParser is a recursive descent parser of filter expression
+type filterParser struct {
+	dialect SqlDialect
+	fields  map[string]FilterField
+	input   string
+	pos     int
+}
+
+// ParseFilter
+/* Function that parses RSQL/FIQL-like filter expression and returns gorm scope with WHERE condition, values are
+ * always passed as query parameters. Grammar:
+ *    - ; (and) has higher priority than , (or), parentheses group expressions: name==bob;(age>30,role=in=(admin,dev))
+ *    - comparisons: == and != (* in unquoted string value is a wildcard: name==bo*), =lt= or <, =le= or <=, =gt= or >,
+ *      =ge= or >=, =in= and =out= with list of values in parentheses, =isnull= with true or false
+ *    - values that contain reserved characters (quotes, parentheses, ;, ',', =, !, ~, <, > and spaces) should be
+ *      quoted with ' or ", \ escapes next character inside quotes
+ * Parameters:
+ *    - dialect - string that represent using db driver inside gorm (see enum above)
+ *    - filter - filter expression, empty filter does not add condition
+ *    - fields - whitelist that maps public field names to columns and types, other fields are not allowed
+ * Returns tuple of gorm scope and error (matches ErrInvalidFilter or ErrInvalidIdentifier if column is not valid)
+ */
+func ParseFilter(dialect SqlDialect, filter string, fields map[string]FilterField) (func(db *g.DB) *g.DB, error) {
+	condition, args, err := parseFilterCondition(dialect, filter, fields)
+	if err != nil {
+		return nil, err
+	}
+	return func(db *g.DB) *g.DB {
+		if len(condition) == 0 {
+			return db
+		}
+		return db.Where(condition, args...)
+	}, nil
+}
+
+// ParseSort
+/* Function that parses sort expression (comma separated fields, - prefix is a descending order, + or no prefix is
+ * ascending order: -created_at,name) and returns gorm scope with ORDER BY
+ * Parameters:
+ *    - dialect - string that represent using db driver inside gorm (see enum above)
+ *    - sort - sort expression, empty expression does not add order
+ *    - fields - whitelist that maps public field names to columns, other fields are not allowed
+ * Returns tuple of gorm scope and error (matches ErrInvalidFilter or ErrInvalidIdentifier if column is not valid)
+ */
+func ParseSort(dialect SqlDialect, sort string, fields map[string]FilterField) (func(db *g.DB) *g.DB, error) {
+	var orders []string
+	if len(strings.TrimSpace(sort)) > 0 {
+		for _, item := range strings.Split(sort, ",") {
+			item = strings.TrimSpace(item)
+			direction := "ASC"
+			if strings.HasPrefix(item, "-") {
+				direction = "DESC"
+				item = item[1:]
+			} else if strings.HasPrefix(item, "+") {
+				item = item[1:]
+			}
+			field, ok := fields[item]
+			if !ok {
+				return nil, newWrappedError(ErrInvalidFilter, nil, "unknown sort field \"{0}\"", item)
+			}
+			quotedColumn, err := quoteColumn(dialect, field.Column)
+			if err != nil {
+				return nil, err
+			}
+			orders = append(orders, quotedColumn+" "+direction)
+		}
+	}
+	return func(db *g.DB) *g.DB {
+		for _, order := range orders {
+			db = db.Order(order)
+		}
+		return db
+	}, nil
+}
+
+// parseFilterCondition
+/* Function that parses filter expression into sql condition with ? placeholders and its parameters, condition is empty
+ * for empty filter
+ */
+func parseFilterCondition(dialect SqlDialect, filter string, fields map[string]FilterField) (string, []interface{},
+	error) {
+	parser := filterParser{dialect: dialect, fields: fields, input: filter}
+	parser.skipSpaces()
+	if parser.pos == len(parser.input) {
+		return "", nil, nil
+	}
+	condition, args, err := parser.parseOr()
+	if err != nil {
+		return "", nil, err
+	}
+	parser.skipSpaces()
+	if parser.pos < len(parser.input) {
+		return "", nil, parser.newError(stringFormatter.Format("unexpected \"{0}\"",
+			parser.input[parser.pos:parser.pos+1]))
+	}
+	return condition, args, nil
+}
+
+// parseOr
+/* Function that parses and expressions separated by , (or)
+ */
+func (p *filterParser) parseOr() (string, []interface{}, error) {
+	return p.parseList(',', " OR ", p.parseAnd)
+}
+
+// parseAnd
+/* Function that parses constraints separated by ; (and)
+ */
+func (p *filterParser) parseAnd() (string, []interface{}, error) {
+	return p.parseList(';', " AND ", p.parseConstraint)
+}
+
+// parseList
+/* Function that parses items separated by separator and joins their conditions with sql operator
+ */
+func (p *filterParser) parseList(separator byte, operator string,
+	parseItem func() (string, []interface{}, error)) (string, []interface{}, error) {
+	var conditions []string
+	var args []interface{}
+	for {
+		condition, itemArgs, err := parseItem()
+		if err != nil {
+			return "", nil, err
+		}
+		conditions = append(conditions, condition)
+		args = append(args, itemArgs...)
+		p.skipSpaces()
+		if p.pos == len(p.input) || p.input[p.pos] != separator {
+			break
+		}
+		p.pos++
+	}
+	if len(conditions) == 1 {
+		return conditions[0], args, nil
+	}
+	return "(" + strings.Join(conditions, operator) + ")", args, nil
+}
+
+// parseConstraint
+/* Function that parses comparison (selector, operator and arguments) or expression in parentheses
+ */
+func (p *filterParser) parseConstraint() (string, []interface{}, error) {
+	p.skipSpaces()
+	if p.pos < len(p.input) && p.input[p.pos] == '(' {
+		p.pos++
+		condition, args, err := p.parseOr()
+		if err != nil {
+			return "", nil, err
+		}
+		p.skipSpaces()
+		if p.pos == len(p.input) || p.input[p.pos] != ')' {
+			return "", nil, p.newError("expected )")
+		}
+		p.pos++
+		return condition, args, nil
+	}
+	start := p.pos
+	for p.pos < len(p.input) && isFilterSelectorChar(p.input[p.pos]) {
+		p.pos++
+	}
+	name := p.input[start:p.pos]
+	if len(name) == 0 {
+		return "", nil, p.newError("expected field name")
+	}
+	field, ok := p.fields[name]
+	if !ok {
+		return "", nil, newWrappedError(ErrInvalidFilter, nil, "unknown field \"{0}\"", name)
+	}
+	quotedColumn, err := quoteColumn(p.dialect, field.Column)
+	if err != nil {
+		return "", nil, err
+	}
+	p.skipSpaces()
+	operator := p.parseOperator()
+	sqlOperator, ok := filterOperators[operator]
+	if !ok {
+		return "", nil, p.newError("expected comparison operator")
+	}
+	p.skipSpaces()
+	values, quoted, isList, err := p.parseArguments()
+	if err != nil {
+		return "", nil, err
+	}
+	if isList && sqlOperator != "IN" && sqlOperator != "NOT IN" {
+		return "", nil, newWrappedError(ErrInvalidFilter, nil, "operator {0} of field \"{1}\" does not accept list",
+			operator, name)
+	}
+	return createFilterCondition(name, field, quotedColumn, sqlOperator, values, quoted)
+}
+
+// parseOperator
+/* Function that reads comparison operator: ==, !=, <, <=, >, >= or =name=
+ */
+func (p *filterParser) parseOperator() string {
+	rest := p.input[p.pos:]
+	for _, operator := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if strings.HasPrefix(rest, operator) {
+			p.pos += len(operator)
+			return operator
+		}
+	}
+	if strings.HasPrefix(rest, "=") {
+		end := strings.IndexByte(rest[1:], '=')
+		if end > 0 {
+			p.pos += end + 2
+			return rest[:end+2]
+		}
+	}
+	return ""
+}
+
+// parseArguments
+/* Function that reads single value or list of values in parentheses
+ * Returns tuple of values, quoted flags of values, list flag and error
+ */
+func (p *filterParser) parseArguments() ([]string, []bool, bool, error) {
+	if p.pos < len(p.input) && p.input[p.pos] == '(' {
+		p.pos++
+		var values []string
+		var quoted []bool
+		for {
+			p.skipSpaces()
+			value, isQuoted, err := p.parseValue()
+			if err != nil {
+				return nil, nil, false, err
+			}
+			values = append(values, value)
+			quoted = append(quoted, isQuoted)
+			p.skipSpaces()
+			if p.pos < len(p.input) && p.input[p.pos] == ',' {
+				p.pos++
+				continue
+			}
+			if p.pos < len(p.input) && p.input[p.pos] == ')' {
+				p.pos++
+				return values, quoted, true, nil
+			}
+			return nil, nil, false, p.newError("expected , or )")
+		}
+	}
+	value, isQuoted, err := p.parseValue()
+	if err != nil {
+		return nil, nil, false, err
+	}
+	return []string{value}, []bool{isQuoted}, false, nil
+}
+
+// parseValue
+/* Function that reads quoted or unquoted value
+ */
+func (p *filterParser) parseValue() (string, bool, error) {
+	if p.pos < len(p.input) && (p.input[p.pos] == '\'' || p.input[p.pos] == '"') {
+		quote := p.input[p.pos]
+		p.pos++
+		var value strings.Builder
+		for p.pos < len(p.input) {
+			char := p.input[p.pos]
+			p.pos++
+			if char == quote {
+				return value.String(), true, nil
+			}
+			if char == '\\' && p.pos < len(p.input) {
+				char = p.input[p.pos]
+				p.pos++
+			}
+			value.WriteByte(char)
+		}
+		return "", false, p.newError("unterminated quoted value")
+	}
+	start := p.pos
+	for p.pos < len(p.input) && !strings.ContainsRune(filterReservedChars, rune(p.input[p.pos])) {
+		p.pos++
+	}
+	if start == p.pos {
+		return "", false, p.newError("expected value")
+	}
+	return p.input[start:p.pos], false, nil
+}
+
+// skipSpaces
+/* Function that skips whitespaces between tokens
+ */
+func (p *filterParser) skipSpaces() {
+	for p.pos < len(p.input) && strings.ContainsRune(" \t\r\n", rune(p.input[p.pos])) {
+		p.pos++
+	}
+}
+
+// newError
+/* Function that creates ErrInvalidFilter error with message and current position
+ */
+func (p *filterParser) newError(message string) error {
+	return newWrappedError(ErrInvalidFilter, nil, "{0} at position {1}", message, p.pos)
+}
+
+// createFilterCondition
+/* Function that converts values to field type and creates sql condition with parameters
+ */
+func createFilterCondition(name string, field FilterField, quotedColumn string, sqlOperator string, values []string,
+	quoted []bool) (string, []interface{}, error) {
+	if sqlOperator == "IS NULL" {
+		isNull, err := strconv.ParseBool(values[0])
+		if err != nil {
+			return "", nil, newWrappedError(ErrInvalidFilter, nil, "=isnull= of field \"{0}\" requires true or false", name)
+		}
+		if !isNull {
+			return quotedColumn + " IS NOT NULL", nil, nil
+		}
+		return quotedColumn + " IS NULL", nil, nil
+	}
+	if field.Type == FilterBool && sqlOperator != "=" && sqlOperator != "<>" && sqlOperator != "IN" &&
+		sqlOperator != "NOT IN" {
+		return "", nil, newWrappedError(ErrInvalidFilter, nil, "field \"{0}\" could not be compared with {1}", name,
+			sqlOperator)
+	}
+	if field.Type == FilterString && !quoted[0] && strings.Contains(values[0], "*") &&
+		(sqlOperator == "=" || sqlOperator == "<>") {
+		operator := "LIKE"
+		if sqlOperator == "<>" {
+			operator = "NOT LIKE"
+		}
+		return quotedColumn + " " + operator + " ? ESCAPE '" + filterLikeEscape + "'",
+			[]interface{}{createLikePattern(values[0])}, nil
+	}
+	args := make([]interface{}, len(values))
+	for i, value := range values {
+		arg, err := convertFilterValue(field.Type, value)
+		if err != nil {
+			return "", nil, newWrappedError(ErrInvalidFilter, err, "value \"{0}\" of field \"{1}\"", value, name)
+		}
+		args[i] = arg
+	}
+	if sqlOperator == "IN" || sqlOperator == "NOT IN" {
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(args)), ", ")
+		return quotedColumn + " " + sqlOperator + " (" + placeholders + ")", args, nil
+	}
+	return quotedColumn + " " + sqlOperator + " ?", args, nil
+}
+
+// convertFilterValue
+/* Function that converts filter value to field type, time value is RFC 3339 time or date (2006-01-02)
+ */
+func convertFilterValue(filterType FilterType, value string) (interface{}, error) {
+	switch filterType {
+	case FilterInt:
+		return strconv.ParseInt(value, 10, 64)
+	case FilterFloat:
+		return strconv.ParseFloat(value, 64)
+	case FilterBool:
+		return strconv.ParseBool(value)
+	case FilterTime:
+		timeValue, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return time.Parse("2006-01-02", value)
+		}
+		return timeValue, nil
+	default:
+		return value, nil
+	}
+}
+
+// createLikePattern
+/* Function that converts value with * wildcards to LIKE pattern, LIKE special characters are escaped
+ */
+func createLikePattern(value string) string {
+	replacer := strings.NewReplacer(filterLikeEscape, filterLikeEscape+filterLikeEscape, "%", filterLikeEscape+"%",
+		"_", filterLikeEscape+"_", "[", filterLikeEscape+"[", "*", "%")
+	return replacer.Replace(value)
+}
+
+// isFilterSelectorChar
+/* Function that checks whether character could be used in field name
+ */
+func isFilterSelectorChar(char byte) bool {
+	return char == '_' || char == '.' || char == '-' || (char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z') ||
+		(char >= '0' && char <= '9')
+}
@@ -0,0 +1,98 @@
+package gorm
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"testing"
+	"time"
+)
+
+var testFilterFields = map[string]FilterField{
+	"name":    {Column: "name"},
+	"age":     {Column: "age", Type: FilterInt},
+	"score":   {Column: "score", Type: FilterFloat},
+	"active":  {Column: "active", Type: FilterBool},
+	"created": {Column: "created_at", Type: FilterTime},
+	"role":    {Column: "roles.name"},
+}
+
+func TestParseFilter(t *testing.T) {
+	condition, args, err := parseFilterCondition(Postgres, "name==bob;age>30,role=in=(admin,dev)", testFilterFields)
+	assert.NoError(t, err)
+	assert.Equal(t, "((\"name\" = ? AND \"age\" > ?) OR \"roles\".\"name\" IN (?, ?))", condition)
+	assert.Equal(t, []interface{}{"bob", int64(30), "admin", "dev"}, args)
+
+	condition, args, err = parseFilterCondition(Mysql, "name==bob;(age=ge=18,active==true);score=lt=2.5",
+		testFilterFields)
+	assert.NoError(t, err)
+	assert.Equal(t, "(`name` = ? AND (`age` >= ? OR `active` = ?) AND `score` < ?)", condition)
+	assert.Equal(t, []interface{}{"bob", int64(18), true, 2.5}, args)
+
+	condition, args, err = parseFilterCondition(Mssql, "name==bo*;created>2024-01-02;role=out=(guest);"+
+		"name=isnull=false", testFilterFields)
+	assert.NoError(t, err)
+	assert.Equal(t, "([name] LIKE ? ESCAPE '!' AND [created_at] > ? AND [roles].[name] NOT IN (?) AND "+
+		"[name] IS NOT NULL)", condition)
+	assert.Equal(t, []interface{}{"bo%", time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), "guest"}, args)
+
+	// quoted values, escapes and LIKE special characters
+	condition, args, err = parseFilterCondition(Postgres, "name=='bo*; \\'b\\'' ; name!=100%_*", testFilterFields)
+	assert.NoError(t, err)
+	assert.Equal(t, "(\"name\" = ? AND \"name\" NOT LIKE ? ESCAPE '!')", condition)
+	assert.Equal(t, []interface{}{"bo*; 'b'", "100!%!_%"}, args)
+
+	condition, args, err = parseFilterCondition(Postgres, " ", testFilterFields)
+	assert.NoError(t, err)
+	assert.Empty(t, condition)
+	assert.Empty(t, args)
+}
+
+func TestParseInvalidFilter(t *testing.T) {
+	filters := []string{
+		"password==123",
+		"name",
+		"name=foo=bar",
+		"name==",
+		"name==bob;",
+		"(name==bob",
+		"name==bob)",
+		"name=='bob",
+		"age==old",
+		"active>true",
+		"name==(a,b)",
+		"name=isnull=maybe",
+		"created==yesterday",
+	}
+	for _, filter := range filters {
+		_, err := ParseFilter(Postgres, filter, testFilterFields)
+		assert.True(t, errors.Is(err, ErrInvalidFilter), filter)
+	}
+	_, err := ParseFilter(Postgres, "bad==1", map[string]FilterField{"bad": {Column: "bad\x00column"}})
+	assert.True(t, errors.Is(err, ErrInvalidIdentifier))
+}
+
+func TestParseSort(t *testing.T) {
+	_, err := ParseSort(Postgres, "-password", testFilterFields)
+	assert.True(t, errors.Is(err, ErrInvalidFilter))
+	_, err = ParseSort(Postgres, "name,", testFilterFields)
+	assert.True(t, errors.Is(err, ErrInvalidFilter))
+}
+
+func TestSqliteFilterWithPagination(t *testing.T) {
+	db, _ := CreateTestDb(t, Sqlite, &gorm.Config{}, nil)
+	prepareDatabase(db)
+	for _, name := range []string{"admin", "developer", "designer", "devops", "guest"} {
+		assert.NoError(t, db.Create(&Role{Name: name}).Error)
+	}
+	fields := map[string]FilterField{"name": {Column: "name"}, "id": {Column: "id", Type: FilterInt}}
+	filter, err := ParseFilter(Sqlite, "name==de*,name=in=(guest)", fields)
+	assert.NoError(t, err)
+	sort, err := ParseSort(Sqlite, "-name, +id", fields)
+	assert.NoError(t, err)
+	var roles []Role
+	assert.NoError(t, db.Scopes(filter, sort, Paginate(1, 2)).Find(&roles).Error)
+	assert.Equal(t, 2, len(roles))
+	assert.Equal(t, "guest", roles[0].Name)
+	assert.Equal(t, "devops", roles[1].Name)
+}
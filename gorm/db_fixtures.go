@@ -0,0 +1,539 @@
+package gorm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"gopkg.in/yaml.v3"
+	g "gorm.io/gorm"
+	"io/fs"
+	"reflect"
+	"strings"
+)
+
+// ErrInvalidFixture is an error of fixture file parsing or references resolving
+var ErrInvalidFixture = errors.New("invalid fixture")
+
+// FixtureReferencePrefix is a prefix of fixture value that references other record by label, i.e. "$ref:admin"
+// value is replaced with id of record that is labelled admin
+const FixtureReferencePrefix = "$ref:"
+
+const fixtureIdColumn = "id"
+
+// FixtureIds is a lookup of created records ids by label
+type FixtureIds map[string]interface{}
+
+// FixtureLoader inserts records from YAML or JSON fixtures, fixture file is a map of tables (table name or registered
+// model name / table) to records map (label to columns map):
+/*
+ *    profiles:
+ *      admin_profile:
+ *        name: Administrator
+ *    User:
+ *      admin:
+ *        UserName: admin
+ *        PasswordHash: "123"
+ *        ProfileId: $ref:admin_profile
+ *
+ * Records of registered models are created with gorm (hooks are called, columns could be field names or column names),
+ * other records are inserted with INSERT statement, their id is read from "id" column (if table has it). Labels are
+ * unique across all loaded files, tables are inserted in foreign keys order (model relations and database foreign
+ * keys), therefore raw foreign key values (i.e. role_id: 1) could be used, records of same table (or of tables with
+ * cyclic foreign keys) are inserted in references order
+ */
+type FixtureLoader struct {
+	dialect SqlDialect
+	db      *g.DB
+	models  map[string]interface{}
+}
+
+// fixtureRecord is a single record of fixture
+type fixtureRecord struct {
+	table   string
+	label   string
+	columns []string
+	values  map[string]interface{}
+	// references are labels that record depends on
+	references []string
+}
+
+// NewFixtureLoader
+/* Function that creates fixture loader
+ * Parameters:
+ *    - dialect - string that represent using db driver inside gorm (see enum above)
+ *    - db - opened database
+ *    - models - models (i.e. &User{}) which records are created with gorm, they are referenced in fixtures by model
+ *               name (User) or table name (users)
+ * Returns tuple of loader and error if model could not be parsed
+ */
+func NewFixtureLoader(dialect SqlDialect, db *g.DB, models ...interface{}) (*FixtureLoader, error) {
+	loader := FixtureLoader{dialect: dialect, db: db, models: map[string]interface{}{}}
+	for _, model := range models {
+		stmt := g.Statement{DB: db}
+		err := stmt.Parse(model)
+		if err != nil {
+			return nil, err
+		}
+		loader.models[stmt.Schema.Name] = model
+		loader.models[stmt.Schema.Table] = model
+	}
+	return &loader, nil
+}
+
+// LoadFiles
+/* Function that reads fixture files (.yml, .yaml or .json) from file system and inserts their records in one
+ * transaction
+ * Parameters:
+ *    - ctx - context that bounds inserting
+ *    - fsys - file system with fixtures (embed.FS, os.DirFS ...)
+ *    - files - paths of files in fsys
+ * Returns tuple of created ids by label and error
+ */
+func (l *FixtureLoader) LoadFiles(ctx context.Context, fsys fs.FS, files ...string) (FixtureIds, error) {
+	data := make([][]byte, 0, len(files))
+	for _, file := range files {
+		content, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, newWrappedError(ErrInvalidFixture, err, "")
+		}
+		data = append(data, content)
+	}
+	return l.Load(ctx, data...)
+}
+
+// Load
+/* Function that parses YAML or JSON fixtures and inserts their records in one transaction
+ * Parameters:
+ *    - ctx - context that bounds inserting
+ *    - data - fixtures content
+ * Returns tuple of created ids by label and error (ErrInvalidFixture if fixture could not be parsed, label is
+ * duplicated, reference is unknown or references are cyclic)
+ */
+func (l *FixtureLoader) Load(ctx context.Context, data ...[]byte) (FixtureIds, error) {
+	records := make([]*fixtureRecord, 0)
+	for _, content := range data {
+		parsed, err := parseFixture(content)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, parsed...)
+	}
+	ids := FixtureIds{}
+	err := l.db.WithContext(ctx).Transaction(func(tx *g.DB) error {
+		tablesOrder, orderErr := l.getTablesOrder(tx, records)
+		if orderErr != nil {
+			return orderErr
+		}
+		ordered, orderErr := sortFixtureRecords(records, tablesOrder)
+		if orderErr != nil {
+			return orderErr
+		}
+		tablesWithId := map[string]bool{}
+		for _, record := range ordered {
+			values := map[string]interface{}{}
+			for _, column := range record.columns {
+				values[column] = resolveFixtureValue(record.values[column], ids)
+			}
+			var id interface{}
+			var insertErr error
+			if model, ok := l.models[record.table]; ok {
+				id, insertErr = createFixtureModel(tx, model, record.columns, values)
+			} else {
+				hasId, checked := tablesWithId[record.table]
+				if !checked {
+					hasId, insertErr = hasIdColumn(tx, record.table)
+					tablesWithId[record.table] = hasId
+				}
+				if insertErr == nil {
+					id, insertErr = l.insertFixtureRow(ctx, tx, record.table, record.columns, values, hasId)
+				}
+			}
+			if insertErr != nil {
+				return newWrappedError(nil, insertErr, "unable to insert fixture \"{0}\" into {1}", record.label,
+					record.table)
+			}
+			ids[record.label] = id
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// parseFixture
+/* Function that parses fixture document keeping tables, records and columns order
+ */
+func parseFixture(content []byte) ([]*fixtureRecord, error) {
+	var document yaml.Node
+	err := yaml.Unmarshal(content, &document)
+	if err != nil {
+		return nil, newWrappedError(ErrInvalidFixture, err, "")
+	}
+	records := make([]*fixtureRecord, 0)
+	if len(document.Content) == 0 {
+		return records, nil
+	}
+	tables := document.Content[0]
+	if tables.Kind != yaml.MappingNode {
+		return nil, newWrappedError(ErrInvalidFixture, nil, "fixture should be a map of tables")
+	}
+	for i := 0; i+1 < len(tables.Content); i += 2 {
+		table := tables.Content[i].Value
+		labels := tables.Content[i+1]
+		if labels.Kind != yaml.MappingNode {
+			return nil, newWrappedError(ErrInvalidFixture, nil, "table {0} should be a map of labelled records", table)
+		}
+		for j := 0; j+1 < len(labels.Content); j += 2 {
+			record, recordErr := parseFixtureRecord(table, labels.Content[j].Value, labels.Content[j+1])
+			if recordErr != nil {
+				return nil, recordErr
+			}
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+// parseFixtureRecord
+/* Function that parses columns of labelled record, values should be scalars
+ */
+func parseFixtureRecord(table string, label string, columns *yaml.Node) (*fixtureRecord, error) {
+	if columns.Kind != yaml.MappingNode {
+		return nil, newWrappedError(ErrInvalidFixture, nil, "record \"{0}\" of {1} should be a map of columns", label,
+			table)
+	}
+	record := fixtureRecord{table: table, label: label, values: map[string]interface{}{}}
+	for k := 0; k+1 < len(columns.Content); k += 2 {
+		column := columns.Content[k].Value
+		valueNode := columns.Content[k+1]
+		if valueNode.Kind != yaml.ScalarNode {
+			return nil, newWrappedError(ErrInvalidFixture, nil, "value of {0} in record \"{1}\" of {2} should be a "+
+				"scalar", column, label, table)
+		}
+		var value interface{}
+		err := valueNode.Decode(&value)
+		if err != nil {
+			return nil, newWrappedError(ErrInvalidFixture, err, "")
+		}
+		if reference, ok := value.(string); ok && strings.HasPrefix(reference, FixtureReferencePrefix) {
+			record.references = append(record.references, strings.TrimPrefix(reference, FixtureReferencePrefix))
+		}
+		record.columns = append(record.columns, column)
+		record.values[column] = value
+	}
+	return &record, nil
+}
+
+// sortFixtureRecords
+/* Function that orders records so that every record goes after records it references and records of tables go after
+ * records of tables they reference (by tablesOrder), records without dependencies between them keep file order
+ */
+func sortFixtureRecords(records []*fixtureRecord, tablesOrder map[string]int) ([]*fixtureRecord, error) {
+	byLabel := map[string]*fixtureRecord{}
+	for _, record := range records {
+		if _, ok := byLabel[record.label]; ok {
+			return nil, newWrappedError(ErrInvalidFixture, nil, "label \"{0}\" is duplicated", record.label)
+		}
+		byLabel[record.label] = record
+	}
+	for _, record := range records {
+		for _, reference := range record.references {
+			if _, ok := byLabel[reference]; !ok {
+				return nil, newWrappedError(ErrInvalidFixture, nil, "record \"{0}\" references unknown label "+
+					"\"{1}\"", record.label, reference)
+			}
+		}
+	}
+	ordered := make([]*fixtureRecord, 0, len(records))
+	inserted := map[string]bool{}
+	for len(ordered) < len(records) {
+		// next record is a first ready record of earliest table
+		var next *fixtureRecord
+		for _, record := range records {
+			if inserted[record.label] || !isFixtureRecordReady(record, inserted) {
+				continue
+			}
+			if next == nil || tablesOrder[record.table] < tablesOrder[next.table] {
+				next = record
+			}
+		}
+		if next == nil {
+			return nil, newWrappedError(ErrInvalidFixture, nil, "records references are cyclic")
+		}
+		ordered = append(ordered, next)
+		inserted[next.label] = true
+	}
+	return ordered, nil
+}
+
+// getTablesOrder
+/* Function that orders tables of records by foreign keys (referenced tables go first), dependencies are taken from
+ * relations of registered models and from foreign keys of database catalog, tables with cyclic foreign keys keep file
+ * order
+ * Returns tuple of table position by table of record (as it is written in fixture) and error of catalog query
+ */
+func (l *FixtureLoader) getTablesOrder(tx *g.DB, records []*fixtureRecord) (map[string]int, error) {
+	tables := make([]string, 0)
+	// fixture tables are keyed by table name, model name and table name of same model are one table, names are
+	// compared case-insensitive
+	tableNames := map[string]string{}
+	originalNames := map[string]string{}
+	for _, record := range records {
+		if _, ok := tableNames[record.table]; ok {
+			continue
+		}
+		table := record.table
+		if model, ok := l.models[record.table]; ok {
+			stmt := g.Statement{DB: tx}
+			err := stmt.Parse(model)
+			if err != nil {
+				return nil, err
+			}
+			table = stmt.Schema.Table
+		}
+		key := strings.ToLower(table)
+		tableNames[record.table] = key
+		if _, ok := originalNames[key]; !ok {
+			originalNames[key] = table
+			tables = append(tables, key)
+		}
+	}
+	dependencies := map[string][]string{}
+	for _, table := range tables {
+		referenced, err := l.getReferencedTables(tx, originalNames[table])
+		if err != nil {
+			return nil, err
+		}
+		dependencies[table] = referenced
+	}
+	for _, model := range l.models {
+		stmt := g.Statement{DB: tx}
+		err := stmt.Parse(model)
+		if err != nil {
+			return nil, err
+		}
+		for _, relation := range stmt.Schema.Relationships.Relations {
+			constraint := relation.ParseConstraint()
+			if constraint == nil || constraint.Schema == nil || constraint.ReferenceSchema == nil {
+				continue
+			}
+			table := strings.ToLower(constraint.Schema.Table)
+			dependencies[table] = append(dependencies[table], strings.ToLower(constraint.ReferenceSchema.Table))
+		}
+	}
+
+	positions := map[string]int{}
+	for len(positions) < len(tables) {
+		next := ""
+		for _, table := range tables {
+			if _, ok := positions[table]; ok || !isFixtureTableReady(table, dependencies[table], originalNames, positions) {
+				continue
+			}
+			next = table
+			break
+		}
+		if next == "" {
+			// tables foreign keys are cyclic, first remaining table is taken, records references define order
+			for _, table := range tables {
+				if _, ok := positions[table]; !ok {
+					next = table
+					break
+				}
+			}
+		}
+		positions[next] = len(positions)
+	}
+	order := map[string]int{}
+	for fixtureTable, table := range tableNames {
+		order[fixtureTable] = positions[table]
+	}
+	return order, nil
+}
+
+// getReferencedTables
+/* Function that reads names of tables that are referenced by foreign keys of table from database catalog
+ */
+func (l *FixtureLoader) getReferencedTables(tx *g.DB, table string) ([]string, error) {
+	tableParam := table
+	var query string
+	switch l.dialect {
+	case Postgres:
+		query = "SELECT DISTINCT c.relname FROM pg_constraint f JOIN pg_class c ON c.oid = f.confrelid " +
+			"WHERE f.contype = 'f' AND f.conrelid = to_regclass(?)"
+	case Mysql:
+		query = "SELECT DISTINCT REFERENCED_TABLE_NAME FROM information_schema.KEY_COLUMN_USAGE " +
+			"WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND REFERENCED_TABLE_NAME IS NOT NULL"
+	case Mssql:
+		query = "SELECT DISTINCT OBJECT_NAME(referenced_object_id) FROM sys.foreign_keys WHERE " +
+			"parent_object_id = OBJECT_ID(?)"
+	case Sqlite:
+		query = "SELECT DISTINCT \"table\" FROM pragma_foreign_key_list(?)"
+	default:
+		return nil, nil
+	}
+	if l.dialect == Postgres || l.dialect == Mssql {
+		// to_regclass and OBJECT_ID parse name, therefore it is quoted
+		quotedTable, err := QuoteIdentifier(l.dialect, table)
+		if err != nil {
+			return nil, err
+		}
+		tableParam = quotedTable
+	}
+	rows, err := tx.Raw(query, tableParam).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	referenced := make([]string, 0)
+	for rows.Next() {
+		var name string
+		err = rows.Scan(&name)
+		if err != nil {
+			return nil, err
+		}
+		referenced = append(referenced, strings.ToLower(name))
+	}
+	return referenced, rows.Err()
+}
+
+// isFixtureTableReady
+/* Function that checks that all fixture tables referenced by table are ordered (self reference and tables that are not
+ * in fixtures are ignored)
+ */
+func isFixtureTableReady(table string, dependencies []string, tables map[string]string, positions map[string]int) bool {
+	for _, dependency := range dependencies {
+		_, inFixtures := tables[dependency]
+		if _, ok := positions[dependency]; !ok && dependency != table && inFixtures {
+			return false
+		}
+	}
+	return true
+}
+
+// isFixtureRecordReady
+/* Function that checks that all records referenced by record are inserted (self reference is not allowed)
+ */
+func isFixtureRecordReady(record *fixtureRecord, inserted map[string]bool) bool {
+	for _, reference := range record.references {
+		if !inserted[reference] {
+			return false
+		}
+	}
+	return true
+}
+
+// resolveFixtureValue
+/* Function that replaces reference with id of referenced record
+ */
+func resolveFixtureValue(value interface{}, ids FixtureIds) interface{} {
+	if reference, ok := value.(string); ok && strings.HasPrefix(reference, FixtureReferencePrefix) {
+		return ids[strings.TrimPrefix(reference, FixtureReferencePrefix)]
+	}
+	return value
+}
+
+// createFixtureModel
+/* Function that creates model instance from values and saves it with gorm
+ * Returns tuple of primary key value and error
+ */
+func createFixtureModel(tx *g.DB, model interface{}, columns []string, values map[string]interface{}) (interface{},
+	error) {
+	instance := reflect.New(reflect.Indirect(reflect.ValueOf(model)).Type())
+	stmt := g.Statement{DB: tx}
+	err := stmt.Parse(instance.Interface())
+	if err != nil {
+		return nil, err
+	}
+	for _, column := range columns {
+		field := stmt.Schema.LookUpField(column)
+		if field == nil {
+			return nil, newWrappedError(ErrInvalidFixture, nil, "{0} has no field {1}", stmt.Schema.Name, column)
+		}
+		err = field.Set(instance.Elem(), values[column])
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = tx.Create(instance.Interface()).Error
+	if err != nil {
+		return nil, err
+	}
+	if stmt.Schema.PrioritizedPrimaryField == nil {
+		return nil, nil
+	}
+	id, _ := stmt.Schema.PrioritizedPrimaryField.ValueOf(instance.Elem())
+	return id, nil
+}
+
+// hasIdColumn
+/* Function that checks that table has id column (Migrator().HasColumn requires model for some dialects)
+ */
+func hasIdColumn(tx *g.DB, table string) (bool, error) {
+	rows, err := tx.Table(table).Where("1 = 0").Rows()
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return false, err
+	}
+	for _, column := range columns {
+		if strings.EqualFold(column, fixtureIdColumn) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// insertFixtureRow
+/* Function that inserts row into table and returns its id: explicit id value, or id that is returned by RETURNING
+ * (Postgres), OUTPUT (Mssql) or LastInsertId (Mysql, Sqlite), nil if table has no id column
+ */
+func (l *FixtureLoader) insertFixtureRow(ctx context.Context, tx *g.DB, table string, columns []string,
+	values map[string]interface{}, hasId bool) (interface{}, error) {
+	quotedTable, err := QuoteIdentifier(l.dialect, table)
+	if err != nil {
+		return nil, err
+	}
+	quotedColumns := make([]string, len(columns))
+	placeholders := make([]string, len(columns))
+	args := make([]interface{}, len(columns))
+	for i, column := range columns {
+		quotedColumns[i], err = QuoteIdentifier(l.dialect, column)
+		if err != nil {
+			return nil, err
+		}
+		placeholders[i] = "?"
+		args[i] = values[column]
+	}
+	id, explicitId := values[fixtureIdColumn]
+	if !hasId || explicitId {
+		statement := "INSERT INTO " + quotedTable + " (" + strings.Join(quotedColumns, ", ") + ") VALUES (" +
+			strings.Join(placeholders, ", ") + ")"
+		return id, tx.Exec(statement, args...).Error
+	}
+	quotedId, _ := QuoteIdentifier(l.dialect, fixtureIdColumn)
+	switch l.dialect {
+	case Postgres:
+		statement := "INSERT INTO " + quotedTable + " (" + strings.Join(quotedColumns, ", ") + ") VALUES (" +
+			strings.Join(placeholders, ", ") + ") RETURNING " + quotedId
+		err = tx.Raw(statement, args...).Row().Scan(&id)
+	case Mssql:
+		statement := "INSERT INTO " + quotedTable + " (" + strings.Join(quotedColumns, ", ") + ") OUTPUT INSERTED." +
+			quotedId + " VALUES (" + strings.Join(placeholders, ", ") + ")"
+		err = tx.Raw(statement, args...).Row().Scan(&id)
+	default:
+		// Mysql and Sqlite drivers use ? placeholders, therefore statement is executed without gorm to get result
+		statement := "INSERT INTO " + quotedTable + " (" + strings.Join(quotedColumns, ", ") + ") VALUES (" +
+			strings.Join(placeholders, ", ") + ")"
+		var result sql.Result
+		result, err = tx.Statement.ConnPool.ExecContext(ctx, statement, args...)
+		if err == nil {
+			id, err = result.LastInsertId()
+		}
+	}
+	return id, err
+}
@@ -0,0 +1,134 @@
+package gorm
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+// users are declared before profiles and roles they reference
+const usersFixture = `
+User:
+  admin:
+    UserName: admin
+    PasswordHash: "123"
+    ProfileId: $ref:admin_profile
+user_roles:
+  admin_is_admin:
+    user_id: $ref:admin
+    role_id: $ref:admin_role
+Profile:
+  admin_profile:
+    Name: Administrator
+roles:
+  admin_role:
+    name: admin
+`
+
+const guestsFixture = `{
+  "users": {
+    "guest": {"user_name": "guest", "password_hash": "456", "profile_id": "$ref:admin_profile"}
+  }
+}`
+
+func TestLoadFixtures(t *testing.T) {
+	db := openSqliteFixturesDb(t)
+	loader, err := NewFixtureLoader(Sqlite, db, &User{}, &Profile{})
+	assert.NoError(t, err)
+	fixtures := fstest.MapFS{"users.yml": {Data: []byte(usersFixture)}, "guests.json": {Data: []byte(guestsFixture)}}
+	ids, err := loader.LoadFiles(context.Background(), fixtures, "users.yml", "guests.json")
+	assert.NoError(t, err)
+	assert.Equal(t, 5, len(ids))
+
+	var admin User
+	assert.NoError(t, db.Preload("Profile").Preload("Roles").First(&admin, ids["admin"]).Error)
+	assert.Equal(t, "admin", admin.UserName)
+	assert.EqualValues(t, ids["admin_profile"], admin.ProfileId)
+	assert.Equal(t, "Administrator", admin.Profile.Name)
+	assert.Equal(t, 1, len(admin.Roles))
+	assert.EqualValues(t, ids["admin_role"], admin.Roles[0].ID)
+	// join table has no id column
+	assert.Nil(t, ids["admin_is_admin"])
+
+	var guest User
+	assert.NoError(t, db.First(&guest, ids["guest"]).Error)
+	assert.Equal(t, admin.ProfileId, guest.ProfileId)
+}
+
+// raw foreign key values, referenced rows are declared after rows that reference them
+const rawKeysFixture = `
+user_roles:
+  raw_is_admin:
+    user_id: $ref:raw_user
+    role_id: 20
+users:
+  raw_user:
+    user_name: raw
+    password_hash: "789"
+    profile_id: 10
+roles:
+  raw_role:
+    id: 20
+    name: admin
+Profile:
+  raw_profile:
+    ID: 10
+    Name: Raw
+`
+
+func TestLoadFixturesInForeignKeysOrder(t *testing.T) {
+	ctx := context.Background()
+	db, err := OpenDb2Context(ctx, Sqlite, filepath.Join(t.TempDir(), "fixtures.db"), true, true, &gorm.Config{}, nil,
+		&OpenOptions{Session: &SessionOptions{Variables: map[string]string{"_foreign_keys": "1"}}})
+	assert.NoError(t, err)
+	defer CloseDb(db)
+	prepareDatabase(db)
+	loader, err := NewFixtureLoader(Sqlite, db, &Profile{})
+	assert.NoError(t, err)
+	ids, err := loader.Load(ctx, []byte(rawKeysFixture))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 10, ids["raw_profile"])
+
+	var user User
+	assert.NoError(t, db.Preload("Profile").Preload("Roles").First(&user, ids["raw_user"]).Error)
+	assert.Equal(t, "Raw", user.Profile.Name)
+	assert.Equal(t, 1, len(user.Roles))
+	assert.EqualValues(t, 20, user.Roles[0].ID)
+}
+
+func TestLoadInvalidFixtures(t *testing.T) {
+	db := openSqliteFixturesDb(t)
+	loader, err := NewFixtureLoader(Sqlite, db, &Role{})
+	assert.NoError(t, err)
+	ctx := context.Background()
+
+	_, err = loader.Load(ctx, []byte("roles:\n  admin:\n    name: $ref:missing\n"))
+	assert.True(t, errors.Is(err, ErrInvalidFixture))
+	_, err = loader.Load(ctx, []byte("roles:\n  admin:\n    name: a\n"), []byte("Role:\n  admin:\n    Name: b\n"))
+	assert.True(t, errors.Is(err, ErrInvalidFixture))
+	_, err = loader.Load(ctx, []byte("roles:\n  first:\n    name: $ref:second\n  second:\n    name: $ref:first\n"))
+	assert.True(t, errors.Is(err, ErrInvalidFixture))
+	_, err = loader.Load(ctx, []byte("roles:\n  admin:\n    name: [a, b]\n"))
+	assert.True(t, errors.Is(err, ErrInvalidFixture))
+	_, err = loader.Load(ctx, []byte("- roles\n"))
+	assert.True(t, errors.Is(err, ErrInvalidFixture))
+	_, err = loader.Load(ctx, []byte("Role:\n  admin:\n    Unknown: a\n"))
+	assert.True(t, errors.Is(err, ErrInvalidFixture))
+
+	// records are inserted in one transaction
+	_, err = loader.Load(ctx, []byte("Role:\n  admin:\n    Name: admin\nmissing_table:\n  row:\n    name: a\n"))
+	assert.Error(t, err)
+	var rolesNumber int64
+	assert.NoError(t, db.Model(&Role{}).Count(&rolesNumber).Error)
+	assert.Equal(t, int64(0), rolesNumber)
+}
+
+func openSqliteFixturesDb(t *testing.T) *gorm.DB {
+	db, _ := CreateTestDb(t, Sqlite, nil, nil)
+	prepareDatabase(db)
+	return db
+}
@@ -0,0 +1,155 @@
+package gorm
+
+import (
+	"context"
+	"database/sql"
+	"github.com/wissance/stringFormatter"
+	g "gorm.io/gorm"
+	"time"
+)
+
+const defaultHealthCheckTimeout = 5 * time.Second
+const defaultPoolSaturationThreshold = 0.9
+
+// HealthCheckOptions is an options of HealthCheckWithOptions
+type HealthCheckOptions struct {
+	// Timeout is a maximum duration of ping query, 5s if not set (ctx deadline is used if it is earlier)
+	Timeout time.Duration
+	// SaturationThreshold is a part of MaxOpenConnections (0 < threshold <= 1) in use starting from which pool is
+	// considered saturated, 0.9 if not set, pool without connections limit is never saturated
+	SaturationThreshold float64
+}
+
+// HealthStatus is a result of database health check, it could be serialized to JSON by readiness probe handler
+type HealthStatus struct {
+	// Healthy is true if ping query succeeded
+	Healthy bool
+	Dialect SqlDialect
+	// Latency is a duration of ping query (including waiting for free connection)
+	Latency time.Duration
+	// ServerVersion is a database server version returned by ping query (empty if query failed)
+	ServerVersion string
+	// Stats are connection pool statistics (open, in use and idle connections, wait count and duration) that were
+	// taken before ping query, therefore ping connection is not counted
+	Stats sql.DBStats
+	// Saturated is true if number of connections in use reached SaturationThreshold of MaxOpenConnections
+	Saturated bool
+	// Warnings are human-readable pool problems (i.e. saturation)
+	Warnings []string
+}
+
+// HealthCheck
+/* Function that checks database availability with default options (5s timeout, pool is saturated at 90% of
+ * connections in use), see HealthCheckWithOptions
+ * Parameters:
+ *    - ctx - context that bounds ping query
+ *    - db - opened database
+ * Returns tuple of health status (it is never nil) and error
+ */
+func HealthCheck(ctx context.Context, db *g.DB) (*HealthStatus, error) {
+	return HealthCheckWithOptions(ctx, db, nil)
+}
+
+// HealthCheckWithOptions
+/* Function that runs dialect specific ping query that returns server version (Postgres - server_version setting,
+ * Mysql - VERSION(), Mssql - SERVERPROPERTY('ProductVersion'), Sqlite - sqlite_version()) with timeout, measures its
+ * latency and reports connection pool statistics with saturation warning
+ * Parameters:
+ *    - ctx - context that bounds ping query
+ *    - db - opened database
+ *    - options - timeout and saturation threshold (could be nil)
+ * Returns tuple of health status (it is never nil, Stats and Warnings are filled even if database is not available)
+ * and error (DbError with ErrHealthCheckFailed kind, ctx error is a Reason if ping query timed out)
+ */
+func HealthCheckWithOptions(ctx context.Context, db *g.DB, options *HealthCheckOptions) (*HealthStatus, error) {
+	timeout := defaultHealthCheckTimeout
+	threshold := defaultPoolSaturationThreshold
+	if options != nil {
+		if options.Timeout > 0 {
+			timeout = options.Timeout
+		}
+		if options.SaturationThreshold > 0 && options.SaturationThreshold <= 1 {
+			threshold = options.SaturationThreshold
+		}
+	}
+	dialect := getDialectOfDb(db)
+	status := HealthStatus{Dialect: dialect}
+	sqlDb, err := db.DB()
+	if err != nil {
+		return &status, newDbErrorContext(ctx, ErrHealthCheckFailed, dialect, "", err)
+	}
+	status.Stats = sqlDb.Stats()
+	status.Saturated, status.Warnings = checkPoolStats(status.Stats, threshold)
+
+	query := getVersionQuery(dialect)
+	if len(query) == 0 {
+		return &status, newDbErrorContext(ctx, ErrHealthCheckFailed, dialect, "", ErrUnsupportedDialect)
+	}
+	pingCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	start := time.Now()
+	err = sqlDb.QueryRowContext(pingCtx, query).Scan(&status.ServerVersion)
+	status.Latency = time.Since(start)
+	if err != nil {
+		return &status, newDbErrorContext(pingCtx, ErrHealthCheckFailed, dialect, "", err)
+	}
+	status.Healthy = true
+	return &status, nil
+}
+
+// getDialectOfDb
+/* Function that returns dialect of opened database by gorm dialector name (empty if dialector is unknown)
+ */
+func getDialectOfDb(db *g.DB) SqlDialect {
+	switch db.Dialector.Name() {
+	case "postgres":
+		return Postgres
+	case "mysql":
+		return Mysql
+	case "sqlserver":
+		return Mssql
+	case "sqlite":
+		return Sqlite
+	default:
+		return ""
+	}
+}
+
+// getVersionQuery
+/* Function that returns query that selects server version as single string value
+ */
+func getVersionQuery(dialect SqlDialect) string {
+	switch dialect {
+	case Postgres:
+		return "SELECT current_setting('server_version')"
+	case Mysql:
+		return "SELECT VERSION()"
+	case Mssql:
+		return "SELECT CAST(SERVERPROPERTY('ProductVersion') AS NVARCHAR(128))"
+	case Sqlite:
+		return "SELECT sqlite_version()"
+	default:
+		return ""
+	}
+}
+
+// checkPoolStats
+/* Function that checks whether pool is saturated (connections in use reached threshold of MaxOpenConnections) and
+ * creates warnings, waits for connection are reported only with saturation because WaitCount is never reset
+ */
+func checkPoolStats(stats sql.DBStats, threshold float64) (bool, []string) {
+	warnings := []string{}
+	if stats.MaxOpenConnections <= 0 {
+		return false, warnings
+	}
+	saturated := float64(stats.InUse) >= threshold*float64(stats.MaxOpenConnections)
+	if saturated {
+		warnings = append(warnings, stringFormatter.Format("connection pool is saturated: {0} of {1} connections are "+
+			"in use", stats.InUse, stats.MaxOpenConnections))
+		if stats.WaitCount > 0 {
+			warnings = append(warnings, stringFormatter.Format("{0} queries waited for free connection, total wait "+
+				"time is {1}", stats.WaitCount, stats.WaitDuration.String()))
+		}
+	}
+	return saturated, warnings
+}
@@ -0,0 +1,55 @@
+package gorm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"testing"
+	"time"
+)
+
+func TestSqliteHealthCheck(t *testing.T) {
+	db, _ := CreateTestDb(t, Sqlite, &gorm.Config{}, nil)
+	status, err := HealthCheck(context.Background(), db)
+	assert.NoError(t, err)
+	assert.True(t, status.Healthy)
+	assert.Equal(t, SqlDialect(Sqlite), status.Dialect)
+	assert.NotEmpty(t, status.ServerVersion)
+	assert.Greater(t, status.Latency, time.Duration(0))
+	assert.False(t, status.Saturated)
+	assert.Empty(t, status.Warnings)
+}
+
+func TestSqliteHealthCheckWhenPoolIsSaturated(t *testing.T) {
+	ctx := context.Background()
+	db, _ := CreateTestDb(t, Sqlite, &gorm.Config{}, nil)
+	sqlDb, err := db.DB()
+	assert.NoError(t, err)
+	sqlDb.SetMaxOpenConns(1)
+	conn, err := sqlDb.Conn(ctx)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	status, err := HealthCheckWithOptions(ctx, db, &HealthCheckOptions{Timeout: 100 * time.Millisecond})
+	assert.True(t, errors.Is(err, ErrHealthCheckFailed))
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.False(t, status.Healthy)
+	assert.True(t, status.Saturated)
+	assert.Equal(t, 1, status.Stats.InUse)
+	assert.Equal(t, []string{"connection pool is saturated: 1 of 1 connections are in use"}, status.Warnings)
+}
+
+func TestCheckPoolStats(t *testing.T) {
+	saturated, warnings := checkPoolStats(sql.DBStats{InUse: 50}, defaultPoolSaturationThreshold)
+	assert.False(t, saturated)
+	assert.Empty(t, warnings)
+	saturated, _ = checkPoolStats(sql.DBStats{MaxOpenConnections: 10, InUse: 8}, defaultPoolSaturationThreshold)
+	assert.False(t, saturated)
+	saturated, warnings = checkPoolStats(sql.DBStats{MaxOpenConnections: 10, InUse: 9, WaitCount: 3,
+		WaitDuration: 1500 * time.Millisecond}, defaultPoolSaturationThreshold)
+	assert.True(t, saturated)
+	assert.Equal(t, []string{"connection pool is saturated: 9 of 10 connections are in use",
+		"3 queries waited for free connection, total wait time is 1.5s"}, warnings)
+}
@@ -0,0 +1,241 @@
+package gorm
+
+import (
+	"context"
+	"errors"
+	"github.com/wissance/stringFormatter"
+	g "gorm.io/gorm"
+	"sync"
+)
+
+const defaultIdCountersTable = "gwuu_id_counters"
+
+// idCounter is a row of counters table
+type idCounter struct {
+	Name  string `gorm:"primaryKey;size:128"`
+	Value int64  `gorm:"not null"`
+}
+
+// idBlock is a block of ids reserved by hi/lo allocator, next id is next, last reserved id is last
+type idBlock struct {
+	next int64
+	last int64
+}
+
+// IdAllocatorOptions is an options of IdAllocator
+type IdAllocatorOptions struct {
+	// UseCounterTable makes Postgres and Mssql allocators use counters table instead of native sequences (Mysql and
+	// Sqlite always use counters table)
+	UseCounterTable bool
+	// CounterTable is a counters table name, gwuu_id_counters if not set
+	CounterTable string
+	// BlockSize enables hi/lo mode if it is greater than 1: sequence (counter) value is a block number (hi) and ids of
+	// block are given from memory without queries, all processes should use same BlockSize for same name
+	BlockSize int64
+}
+
+// IdAllocator allocates unique ids that are safe to use from concurrent goroutines and processes (unlike
+// GetNextTableId that returns MAX(id) + 1), ids are taken from:
+//   - native sequence - Postgres (nextval) and Mssql (NEXT VALUE FOR), sequence is created if it does not exist
+//   - counters table - Mysql, Sqlite or if UseCounterTable is set, counter row is incremented atomically
+//     (upsert with RETURNING, LAST_INSERT_ID(expr) or MERGE ... OUTPUT)
+type IdAllocator struct {
+	dialect      SqlDialect
+	db           *g.DB
+	useSequences bool
+	counterTable string
+	blockSize    int64
+	mutex        sync.Mutex
+	sequences    map[string]bool
+	blocks       map[string]*idBlock
+}
+
+// NewIdAllocator
+/* Function that creates id allocator, counters table is created if allocator uses it and table does not exist
+ * Parameters:
+ *    - dialect - string that represent using db driver inside gorm (see enum above)
+ *    - db - opened database
+ *    - options - allocator options (could be nil)
+ * Returns tuple of allocator and error (matches ErrInvalidIdentifier if counters table name is not valid)
+ */
+func NewIdAllocator(dialect SqlDialect, db *g.DB, options *IdAllocatorOptions) (*IdAllocator, error) {
+	if dialect != Postgres && dialect != Mysql && dialect != Mssql && dialect != Sqlite {
+		return nil, ErrUnsupportedDialect
+	}
+	allocator := IdAllocator{dialect: dialect, db: db, counterTable: defaultIdCountersTable, blockSize: 1,
+		useSequences: dialect == Postgres || dialect == Mssql, sequences: map[string]bool{},
+		blocks: map[string]*idBlock{}}
+	if options != nil {
+		if options.UseCounterTable {
+			allocator.useSequences = false
+		}
+		if len(options.CounterTable) > 0 {
+			allocator.counterTable = options.CounterTable
+		}
+		if options.BlockSize > 1 {
+			allocator.blockSize = options.BlockSize
+		}
+	}
+	if !allocator.useSequences {
+		err := ValidateIdentifier(dialect, allocator.counterTable)
+		if err != nil {
+			return nil, err
+		}
+		err = db.Table(allocator.counterTable).AutoMigrate(&idCounter{})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &allocator, nil
+}
+
+// NextId
+/* Function that allocates next id
+ * Parameters:
+ *    - ctx - context that bounds queries
+ *    - name - sequence or counter name (i.e. users_id_seq), it is validated as identifier
+ * Returns tuple of id and error
+ */
+func (a *IdAllocator) NextId(ctx context.Context, name string) (int64, error) {
+	ids, err := a.NextIds(ctx, name, 1)
+	if err != nil {
+		return 0, err
+	}
+	return ids[0], nil
+}
+
+// NextIds
+/* Function that allocates number of ids (i.e. for batch insert), ids are unique but they are not always consecutive
+ * (sequence mode without blocks)
+ * Parameters:
+ *    - ctx - context that bounds queries
+ *    - name - sequence or counter name
+ *    - count - number of ids
+ * Returns tuple of ids and error
+ */
+func (a *IdAllocator) NextIds(ctx context.Context, name string, count int) ([]int64, error) {
+	if count < 1 {
+		return nil, errors.New(stringFormatter.Format("ids count should be positive, got {0}", count))
+	}
+	quotedName, err := QuoteIdentifier(a.dialect, name)
+	if err != nil {
+		return nil, err
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	ids := make([]int64, 0, count)
+	block := a.blocks[name]
+	for len(ids) < count {
+		if block == nil || block.next > block.last {
+			hi, err := a.nextValue(ctx, name, quotedName)
+			if err != nil {
+				return nil, err
+			}
+			// hi starts from 1, block ids are (hi - 1) * blockSize + 1 ... hi * blockSize
+			block = &idBlock{next: (hi-1)*a.blockSize + 1, last: hi * a.blockSize}
+			a.blocks[name] = block
+		}
+		ids = append(ids, block.next)
+		block.next++
+	}
+	return ids, nil
+}
+
+// nextValue
+/* Function that increments sequence or counter and returns its value
+ */
+func (a *IdAllocator) nextValue(ctx context.Context, name string, quotedName string) (int64, error) {
+	db := a.db.WithContext(ctx)
+	if a.useSequences {
+		err := a.ensureSequence(db, name, quotedName)
+		if err != nil {
+			return 0, err
+		}
+		// text parameter is implicitly casted to regclass
+		query := "SELECT nextval(CAST(? AS TEXT))"
+		if a.dialect == Mssql {
+			query = "SELECT NEXT VALUE FOR " + quotedName
+			return scanIdValue(db.Raw(query))
+		}
+		return scanIdValue(db.Raw(query, quotedName))
+	}
+	quotedTable, _ := QuoteIdentifier(a.dialect, a.counterTable)
+	switch a.dialect {
+	case Postgres:
+		return scanIdValue(db.Raw(stringFormatter.Format("INSERT INTO {0} (name, value) VALUES (?, 1) "+
+			"ON CONFLICT (name) DO UPDATE SET value = {0}.value + 1 RETURNING value", quotedTable), name))
+	case Mysql:
+		// LAST_INSERT_ID(expr) value is returned as last insert id of statement
+		result, err := db.Statement.ConnPool.ExecContext(ctx, stringFormatter.Format("INSERT INTO {0} (name, value) "+
+			"VALUES (?, LAST_INSERT_ID(1)) ON DUPLICATE KEY UPDATE value = LAST_INSERT_ID(value + 1)", quotedTable), name)
+		if err != nil {
+			return 0, err
+		}
+		return result.LastInsertId()
+	case Mssql:
+		return scanIdValue(db.Raw(stringFormatter.Format("MERGE {0} WITH (HOLDLOCK) AS target "+
+			"USING (SELECT ? AS name) AS source ON target.name = source.name "+
+			"WHEN MATCHED THEN UPDATE SET value = target.value + 1 "+
+			"WHEN NOT MATCHED THEN INSERT (name, value) VALUES (source.name, 1) OUTPUT INSERTED.value;", quotedTable),
+			name))
+	default:
+		// Sqlite write transaction locks database, therefore upsert and select are atomic
+		var value int64
+		err := db.Transaction(func(tx *g.DB) error {
+			err := tx.Exec(stringFormatter.Format("INSERT INTO {0} (name, value) VALUES (?, 1) "+
+				"ON CONFLICT (name) DO UPDATE SET value = value + 1", quotedTable), name).Error
+			if err != nil {
+				return err
+			}
+			value, err = scanIdValue(tx.Raw(stringFormatter.Format("SELECT value FROM {0} WHERE name = ?", quotedTable),
+				name))
+			return err
+		})
+		return value, err
+	}
+}
+
+// ensureSequence
+/* Function that creates native sequence if it was not checked by allocator before, error of creation is ignored if
+ * sequence was created concurrently by other process
+ */
+func (a *IdAllocator) ensureSequence(db *g.DB, name string, quotedName string) error {
+	if a.sequences[name] {
+		return nil
+	}
+	var err error
+	if a.dialect == Postgres {
+		err = db.Exec(stringFormatter.Format("CREATE SEQUENCE IF NOT EXISTS {0}", quotedName)).Error
+	} else {
+		err = db.Exec(stringFormatter.Format("IF OBJECT_ID(?, 'SO') IS NULL CREATE SEQUENCE {0} AS BIGINT "+
+			"START WITH 1 INCREMENT BY 1", quotedName), quotedName).Error
+	}
+	if err != nil {
+		var exists int64
+		query := "SELECT COUNT(*) FROM pg_class WHERE oid = to_regclass(CAST(? AS TEXT))"
+		if a.dialect == Mssql {
+			query = "SELECT COUNT(*) FROM sys.sequences WHERE object_id = OBJECT_ID(?)"
+		}
+		checkErr := db.Raw(query, quotedName).Scan(&exists).Error
+		if checkErr != nil || exists == 0 {
+			return err
+		}
+	}
+	a.sequences[name] = true
+	return nil
+}
+
+// scanIdValue
+/* Function that reads single int64 value of query
+ */
+func scanIdValue(query *g.DB) (int64, error) {
+	var value int64
+	result := query.Scan(&value)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return 0, errors.New("id value was not returned")
+	}
+	return value, nil
+}
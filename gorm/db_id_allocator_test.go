@@ -0,0 +1,113 @@
+package gorm
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"sync"
+	"testing"
+)
+
+func TestSqliteIdAllocator(t *testing.T) {
+	ctx := context.Background()
+	db, _ := CreateTestDb(t, Sqlite, &gorm.Config{}, nil)
+	allocator, err := NewIdAllocator(Sqlite, db, nil)
+	assert.NoError(t, err)
+	assert.True(t, db.Migrator().HasTable(defaultIdCountersTable))
+	for i := int64(1); i <= 3; i++ {
+		id, err := allocator.NextId(ctx, "users")
+		assert.NoError(t, err)
+		assert.Equal(t, i, id)
+	}
+	// counters are independent
+	id, err := allocator.NextId(ctx, "roles")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), id)
+	ids, err := allocator.NextIds(ctx, "users", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{4, 5}, ids)
+
+	// other allocator (process) continues counter
+	otherAllocator, err := NewIdAllocator(Sqlite, db, nil)
+	assert.NoError(t, err)
+	id, err = otherAllocator.NextId(ctx, "users")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(6), id)
+
+	_, err = allocator.NextId(ctx, "bad\x00name")
+	assert.True(t, errors.Is(err, ErrInvalidIdentifier))
+	_, err = allocator.NextIds(ctx, "users", 0)
+	assert.Error(t, err)
+}
+
+func TestSqliteIdAllocatorBlocks(t *testing.T) {
+	ctx := context.Background()
+	db, _ := CreateTestDb(t, Sqlite, &gorm.Config{}, nil)
+	options := IdAllocatorOptions{CounterTable: "id_blocks", BlockSize: 10}
+	first, err := NewIdAllocator(Sqlite, db, &options)
+	assert.NoError(t, err)
+	second, err := NewIdAllocator(Sqlite, db, &options)
+	assert.NoError(t, err)
+
+	ids, err := first.NextIds(ctx, "users", 12)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), ids[0])
+	assert.Equal(t, int64(12), ids[11])
+	// second allocator reserves third block
+	id, err := second.NextId(ctx, "users")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(21), id)
+	// first allocator uses rest of its block without queries
+	id, err = first.NextId(ctx, "users")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(13), id)
+	var blocksNumber int64
+	assert.NoError(t, db.Raw("SELECT value FROM id_blocks WHERE name = ?", "users").Scan(&blocksNumber).Error)
+	assert.Equal(t, int64(3), blocksNumber)
+}
+
+func TestSqliteIdAllocatorConcurrentUse(t *testing.T) {
+	db, _ := CreateTestDb(t, Sqlite, &gorm.Config{}, nil)
+	allocator, err := NewIdAllocator(Sqlite, db, &IdAllocatorOptions{BlockSize: 5})
+	assert.NoError(t, err)
+	var mutex sync.Mutex
+	allocated := map[int64]bool{}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				id, err := allocator.NextId(context.Background(), "users")
+				assert.NoError(t, err)
+				mutex.Lock()
+				assert.False(t, allocated[id])
+				allocated[id] = true
+				mutex.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 100, len(allocated))
+}
+
+func TestPostgresIdAllocatorSequences(t *testing.T) {
+	ctx := context.Background()
+	db, _ := CreateTestDb(t, Postgres, &gorm.Config{}, nil)
+	allocator, err := NewIdAllocator(Postgres, db, nil)
+	assert.NoError(t, err)
+	assert.False(t, db.Migrator().HasTable(defaultIdCountersTable))
+	id, err := allocator.NextId(ctx, "Users_Seq")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), id)
+	id, err = allocator.NextId(ctx, "Users_Seq")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), id)
+
+	counterAllocator, err := NewIdAllocator(Postgres, db, &IdAllocatorOptions{UseCounterTable: true})
+	assert.NoError(t, err)
+	id, err = counterAllocator.NextId(ctx, "users")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), id)
+}
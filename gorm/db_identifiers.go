@@ -0,0 +1,109 @@
+package gorm
+
+import (
+	"github.com/wissance/stringFormatter"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const postgresMaxIdentifierLength = 63
+const mysqlMaxIdentifierLength = 64
+const mssqlMaxIdentifierLength = 128
+
+// ValidateIdentifier
+/* Function that checks that name could be used as database object identifier (database, schema, table or column name)
+ * of dialect. Mixed case, dashes, spaces and quote characters are allowed because identifier is quoted by
+ * QuoteIdentifier, following names are invalid:
+ *    - empty name, name with control characters or invalid UTF-8
+ *    - name that is longer than dialect allows (Postgres - 63 bytes, Mysql - 64 characters, Mssql - 128 characters)
+ *    - Mysql name that contains '/', '\', '.' or ends with space
+ * Parameters:
+ *    - dialect - string that represent using db driver inside gorm (see enum above)
+ *    - name - identifier
+ * Returns nil if identifier is valid, otherwise error that matches ErrInvalidIdentifier
+ */
+func ValidateIdentifier(dialect SqlDialect, name string) error {
+	if len(name) == 0 {
+		return newIdentifierError(name, "is empty")
+	}
+	if !utf8.ValidString(name) {
+		return newIdentifierError(name, "is not a valid UTF-8 string")
+	}
+	for _, symbol := range name {
+		if unicode.IsControl(symbol) {
+			return newIdentifierError(name, "contains control characters")
+		}
+	}
+	switch dialect {
+	case Postgres:
+		if len(name) > postgresMaxIdentifierLength {
+			return newIdentifierError(name, stringFormatter.Format("is longer than {0} bytes", postgresMaxIdentifierLength))
+		}
+	case Mysql:
+		if utf8.RuneCountInString(name) > mysqlMaxIdentifierLength {
+			return newIdentifierError(name, stringFormatter.Format("is longer than {0} characters", mysqlMaxIdentifierLength))
+		}
+		if strings.ContainsAny(name, "/\\.") {
+			return newIdentifierError(name, "contains '/', '\\' or '.'")
+		}
+		if strings.HasSuffix(name, " ") {
+			return newIdentifierError(name, "ends with space")
+		}
+	case Mssql:
+		if utf8.RuneCountInString(name) > mssqlMaxIdentifierLength {
+			return newIdentifierError(name, stringFormatter.Format("is longer than {0} characters", mssqlMaxIdentifierLength))
+		}
+	case Sqlite:
+	default:
+		return ErrUnsupportedDialect
+	}
+	return nil
+}
+
+// QuoteIdentifier
+/* Function that validates (see ValidateIdentifier) and quotes identifier according to dialect: double quotes for
+ * Postgres and Sqlite, backticks for Mysql, brackets for Mssql. Closing quote character inside name is doubled,
+ * therefore quoted identifier is safe to use in SQL statement
+ * Parameters:
+ *    - dialect - string that represent using db driver inside gorm (see enum above)
+ *    - name - identifier
+ * Returns tuple of quoted identifier and error (matches ErrInvalidIdentifier or ErrUnsupportedDialect)
+ */
+func QuoteIdentifier(dialect SqlDialect, name string) (string, error) {
+	err := ValidateIdentifier(dialect, name)
+	if err != nil {
+		return "", err
+	}
+	switch dialect {
+	case Mysql:
+		return "`" + strings.ReplaceAll(name, "`", "``") + "`", nil
+	case Mssql:
+		return "[" + strings.ReplaceAll(name, "]", "]]") + "]", nil
+	default:
+		return "\"" + strings.ReplaceAll(name, "\"", "\"\"") + "\"", nil
+	}
+}
+
+// quoteColumn
+/* Function that quotes column name that could be qualified with table name (users.id), every part is quoted with
+ * QuoteIdentifier
+ */
+func quoteColumn(dialect SqlDialect, column string) (string, error) {
+	parts := strings.Split(column, ".")
+	for i, part := range parts {
+		quotedPart, err := QuoteIdentifier(dialect, part)
+		if err != nil {
+			return "", err
+		}
+		parts[i] = quotedPart
+	}
+	return strings.Join(parts, "."), nil
+}
+
+// newIdentifierError
+/* Function that creates error that wraps ErrInvalidIdentifier with identifier and reason
+ */
+func newIdentifierError(name string, reason string) error {
+	return newWrappedError(ErrInvalidIdentifier, nil, "\"{0}\" {1}", name, reason)
+}
@@ -0,0 +1,50 @@
+package gorm
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"strings"
+	"testing"
+)
+
+func TestQuoteIdentifier(t *testing.T) {
+	checkQuoteIdentifier(t, Postgres, "Tenant-App", "\"Tenant-App\"")
+	checkQuoteIdentifier(t, Postgres, "app\"; DROP DATABASE postgres; --", "\"app\"\"; DROP DATABASE postgres; --\"")
+	checkQuoteIdentifier(t, Mysql, "Tenant-App", "`Tenant-App`")
+	checkQuoteIdentifier(t, Mysql, "app`; DROP DATABASE mysql; --", "`app``; DROP DATABASE mysql; --`")
+	checkQuoteIdentifier(t, Mssql, "Tenant-App", "[Tenant-App]")
+	checkQuoteIdentifier(t, Mssql, "app]; DROP DATABASE master; --", "[app]]; DROP DATABASE master; --]")
+	checkQuoteIdentifier(t, Sqlite, "users", "\"users\"")
+}
+
+func TestQuoteInvalidIdentifier(t *testing.T) {
+	checkInvalidIdentifier(t, Postgres, "")
+	checkInvalidIdentifier(t, Postgres, "app\x00")
+	checkInvalidIdentifier(t, Postgres, "app\n")
+	checkInvalidIdentifier(t, Postgres, strings.Repeat("a", 64))
+	checkInvalidIdentifier(t, Mysql, "app.db")
+	checkInvalidIdentifier(t, Mysql, "app ")
+	checkInvalidIdentifier(t, Mysql, strings.Repeat("a", 65))
+	checkInvalidIdentifier(t, Mssql, strings.Repeat("a", 129))
+	_, err := QuoteIdentifier("oracle", "app")
+	assert.True(t, errors.Is(err, ErrUnsupportedDialect))
+}
+
+func TestDropDbWithInvalidName(t *testing.T) {
+	cfg := gorm.Config{}
+	err := DropDb2WithError(Postgres, "host=localhost port=5432 user=root dbname=postgres", "app\x00", &cfg)
+	assert.True(t, errors.Is(err, ErrDropFailed))
+	assert.True(t, errors.Is(err, ErrInvalidIdentifier))
+}
+
+func checkQuoteIdentifier(t *testing.T, dialect SqlDialect, name string, expectedQuotedName string) {
+	quotedName, err := QuoteIdentifier(dialect, name)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedQuotedName, quotedName)
+}
+
+func checkInvalidIdentifier(t *testing.T, dialect SqlDialect, name string) {
+	_, err := QuoteIdentifier(dialect, name)
+	assert.True(t, errors.Is(err, ErrInvalidIdentifier), name)
+}
@@ -0,0 +1,356 @@
+package gorm
+
+import (
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/wissance/stringFormatter"
+	g "gorm.io/gorm"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCursor is an error of keyset pagination cursor decoding (cursor is damaged or belongs to other sort columns)
+var ErrInvalidCursor = errors.New("invalid pagination cursor")
+
+// ErrInvalidKeysetPagination is an error of keyset pagination usage (no sort columns, rows are not a slice or sort column
+// is missing in rows)
+var ErrInvalidKeysetPagination = errors.New("invalid keyset pagination")
+
+// cursor value types (keysetValue.Type)
+const (
+	keysetInt    = "i"
+	keysetUint   = "u"
+	keysetFloat  = "f"
+	keysetString = "s"
+	keysetBool   = "b"
+	keysetTime   = "t"
+	keysetBytes  = "x"
+)
+
+// KeysetColumn is a sort column of keyset pagination
+type KeysetColumn struct {
+	// Column is a column name, it could be qualified with table name (users.id)
+	Column string
+	// Desc is a descending sort order
+	Desc bool
+}
+
+// KeysetPage is cursors of selected page, cursor is empty if there is no page in that direction
+type KeysetPage struct {
+	NextCursor string
+	PrevCursor string
+}
+
+// KeysetPagination is a keyset (cursor) pagination that selects rows after (or before) row which sort columns values
+// are stored in cursor, unlike Paginate it does not scan skipped rows and does not skip or duplicate rows when rows are
+// inserted or deleted between requests
+type KeysetPagination struct {
+	dialect       SqlDialect
+	columns       []KeysetColumn
+	quotedColumns []string
+	size          int
+	backward      bool
+	values        []interface{}
+}
+
+// keysetCursor is a decoded cursor, Backward cursor selects rows before values
+type keysetCursor struct {
+	Backward bool          `json:"b,omitempty"`
+	Values   []keysetValue `json:"v"`
+}
+
+// keysetValue is a typed cursor value, value is stored as string to keep precision of int64 and time
+type keysetValue struct {
+	Type  string `json:"t"`
+	Value string `json:"v"`
+}
+
+// NewKeysetPagination
+/* Function that creates keyset pagination, rows are sorted by columns, therefore columns should identify row
+ * uniquely (last column is usually a primary key) and should not be NULL
+ * Parameters:
+ *    - dialect - string that represent using db driver inside gorm (see enum above)
+ *    - columns - sort columns
+ *    - cursor - NextCursor or PrevCursor of previous page (KeysetPage), empty cursor selects first page
+ *    - size - number of rows to select, if it is less than 1 default size is used
+ * Returns tuple of pagination and error (matches ErrInvalidCursor if cursor could not be decoded,
+ * ErrInvalidIdentifier if column name is not valid or ErrInvalidKeysetPagination if there are no columns)
+ */
+func NewKeysetPagination(dialect SqlDialect, columns []KeysetColumn, cursor string, size int) (*KeysetPagination,
+	error) {
+	if len(columns) == 0 {
+		return nil, newWrappedError(ErrInvalidKeysetPagination, nil, "at least one sort column is required")
+	}
+	if size < 1 {
+		size = defaultPageSize
+	}
+	pagination := KeysetPagination{dialect: dialect, columns: columns, size: size,
+		quotedColumns: make([]string, len(columns))}
+	for i, column := range columns {
+		quotedColumn, err := quoteColumn(dialect, column.Column)
+		if err != nil {
+			return nil, err
+		}
+		pagination.quotedColumns[i] = quotedColumn
+	}
+	if len(cursor) > 0 {
+		decoded, err := decodeKeysetCursor(cursor, len(columns))
+		if err != nil {
+			return nil, err
+		}
+		pagination.backward = decoded.Backward
+		pagination.values = make([]interface{}, len(decoded.Values))
+		for i, value := range decoded.Values {
+			pagination.values[i], err = value.decode()
+			if err != nil {
+				return nil, newWrappedError(ErrInvalidCursor, err, "")
+			}
+		}
+	}
+	return &pagination, nil
+}
+
+// Scope
+/* Function that returns gorm scope which selects page: condition on sort columns values from cursor (row value
+ * comparison (a, b) > (?, ?) or a > ? OR (a = ? AND b > ?) for Mssql and mixed sort directions), ORDER BY sort
+ * columns (reversed for backward cursor) and LIMIT size + 1 (extra row shows that there is one more page)
+ * Returns gorm scope, query result should be passed to Page
+ */
+func (p *KeysetPagination) Scope() func(db *g.DB) *g.DB {
+	return func(db *g.DB) *g.DB {
+		if p.values != nil {
+			condition, args := p.getCondition()
+			db = db.Where(condition, args...)
+		}
+		for i, column := range p.columns {
+			direction := "ASC"
+			if column.Desc != p.backward {
+				direction = "DESC"
+			}
+			db = db.Order(p.quotedColumns[i] + " " + direction)
+		}
+		return db.Limit(p.size + 1)
+	}
+}
+
+// Page
+/* Function that removes extra row from rows that were selected with Scope, restores rows order for backward cursor and
+ * creates cursors of next and previous pages from last and first row
+ * Parameters:
+ *    - db - database that was used for query (it is required to find model fields by column names)
+ *    - rows - pointer to slice of models (or pointers to models) or maps (map[string]interface{})
+ * Returns tuple of page cursors and error if column value could not be read from row or encoded (matches
+ * ErrInvalidKeysetPagination if rows are not a pointer to slice or row has no sort column)
+ */
+func (p *KeysetPagination) Page(db *g.DB, rows interface{}) (KeysetPage, error) {
+	page := KeysetPage{}
+	rowsValue := reflect.ValueOf(rows)
+	if rowsValue.Kind() != reflect.Ptr || rowsValue.Elem().Kind() != reflect.Slice {
+		return page, newWrappedError(ErrInvalidKeysetPagination, nil, "rows should be a pointer to slice")
+	}
+	rowsValue = rowsValue.Elem()
+	hasMore := rowsValue.Len() > p.size
+	if hasMore {
+		rowsValue.Set(rowsValue.Slice(0, p.size))
+	}
+	rowsNumber := rowsValue.Len()
+	if p.backward {
+		swap := reflect.Swapper(rowsValue.Interface())
+		for i := 0; i < rowsNumber/2; i++ {
+			swap(i, rowsNumber-1-i)
+		}
+	}
+	if rowsNumber == 0 {
+		return page, nil
+	}
+	hasNext := hasMore || p.backward
+	hasPrev := p.values != nil && (hasMore || !p.backward)
+	var err error
+	if hasNext {
+		page.NextCursor, err = p.createCursor(db, rows, rowsValue.Index(rowsNumber-1), false)
+		if err != nil {
+			return page, err
+		}
+	}
+	if hasPrev {
+		page.PrevCursor, err = p.createCursor(db, rows, rowsValue.Index(0), true)
+		if err != nil {
+			return page, err
+		}
+	}
+	return page, nil
+}
+
+// getCondition
+/* Function that creates WHERE condition that selects rows after (before for backward cursor) cursor values
+ */
+func (p *KeysetPagination) getCondition() (string, []interface{}) {
+	sameDirection := true
+	for _, column := range p.columns {
+		sameDirection = sameDirection && column.Desc == p.columns[0].Desc
+	}
+	getOperator := func(column KeysetColumn) string {
+		if column.Desc != p.backward {
+			return "<"
+		}
+		return ">"
+	}
+	if len(p.columns) == 1 {
+		return p.quotedColumns[0] + " " + getOperator(p.columns[0]) + " ?", p.values
+	}
+	// Mssql has no row value comparison
+	if sameDirection && p.dialect != Mssql {
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(p.columns)), ", ")
+		return "(" + strings.Join(p.quotedColumns, ", ") + ") " + getOperator(p.columns[0]) + " (" + placeholders + ")",
+			p.values
+	}
+	conditions := make([]string, len(p.columns))
+	var args []interface{}
+	for i, column := range p.columns {
+		parts := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			parts = append(parts, p.quotedColumns[j]+" = ?")
+			args = append(args, p.values[j])
+		}
+		parts = append(parts, p.quotedColumns[i]+" "+getOperator(column)+" ?")
+		args = append(args, p.values[i])
+		conditions[i] = "(" + strings.Join(parts, " AND ") + ")"
+	}
+	return "(" + strings.Join(conditions, " OR ") + ")", args
+}
+
+// createCursor
+/* Function that reads sort columns values from row and encodes them into URL-safe cursor
+ */
+func (p *KeysetPagination) createCursor(db *g.DB, rows interface{}, row reflect.Value, backward bool) (string, error) {
+	row = reflect.Indirect(row)
+	cursor := keysetCursor{Backward: backward, Values: make([]keysetValue, len(p.columns))}
+	var stmt *g.Statement
+	for i, column := range p.columns {
+		name := column.Column[strings.LastIndex(column.Column, ".")+1:]
+		var value interface{}
+		if row.Kind() == reflect.Map {
+			mapValue := row.MapIndex(reflect.ValueOf(name))
+			if !mapValue.IsValid() {
+				return "", newWrappedError(ErrInvalidKeysetPagination, nil, "row has no column {0}", name)
+			}
+			value = mapValue.Interface()
+		} else {
+			if stmt == nil {
+				stmt = &g.Statement{DB: db}
+				err := stmt.Parse(rows)
+				if err != nil {
+					return "", err
+				}
+			}
+			field := stmt.Schema.LookUpField(name)
+			if field == nil {
+				return "", newWrappedError(ErrInvalidKeysetPagination, nil, "model {0} has no field {1}",
+					stmt.Schema.Name, name)
+			}
+			value, _ = field.ValueOf(row)
+		}
+		encoded, err := encodeKeysetValue(value)
+		if err != nil {
+			return "", newWrappedError(nil, err, "keyset pagination column {0}", name)
+		}
+		cursor.Values[i] = encoded
+	}
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeKeysetCursor
+/* Function that decodes URL-safe cursor and checks number of values
+ */
+func decodeKeysetCursor(cursor string, columnsNumber int) (keysetCursor, error) {
+	decoded := keysetCursor{}
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return decoded, newWrappedError(ErrInvalidCursor, err, "")
+	}
+	err = json.Unmarshal(data, &decoded)
+	if err != nil {
+		return decoded, newWrappedError(ErrInvalidCursor, err, "")
+	}
+	if len(decoded.Values) != columnsNumber {
+		return decoded, newWrappedError(ErrInvalidCursor, nil, "cursor has {0} values, but there are {1} sort "+
+			"columns", len(decoded.Values), columnsNumber)
+	}
+	return decoded, nil
+}
+
+// encodeKeysetValue
+/* Function that converts column value to typed cursor value, driver.Valuer (i.e. sql.NullInt64) is converted to its
+ * driver value, NULL values are not supported
+ */
+func encodeKeysetValue(value interface{}) (keysetValue, error) {
+	if valuer, ok := value.(driver.Valuer); ok {
+		driverValue, err := valuer.Value()
+		if err != nil {
+			return keysetValue{}, err
+		}
+		value = driverValue
+	}
+	if timeValue, ok := value.(time.Time); ok {
+		return keysetValue{Type: keysetTime, Value: timeValue.Format(time.RFC3339Nano)}, nil
+	}
+	if bytesValue, ok := value.([]byte); ok {
+		return keysetValue{Type: keysetBytes, Value: base64.StdEncoding.EncodeToString(bytesValue)}, nil
+	}
+	reflectValue := reflect.ValueOf(value)
+	if reflectValue.Kind() == reflect.Ptr {
+		if reflectValue.IsNil() {
+			return keysetValue{}, errors.New("NULL value could not be used in cursor")
+		}
+		return encodeKeysetValue(reflectValue.Elem().Interface())
+	}
+	switch reflectValue.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return keysetValue{Type: keysetInt, Value: strconv.FormatInt(reflectValue.Int(), 10)}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return keysetValue{Type: keysetUint, Value: strconv.FormatUint(reflectValue.Uint(), 10)}, nil
+	case reflect.Float32, reflect.Float64:
+		return keysetValue{Type: keysetFloat, Value: strconv.FormatFloat(reflectValue.Float(), 'g', -1, 64)}, nil
+	case reflect.String:
+		return keysetValue{Type: keysetString, Value: reflectValue.String()}, nil
+	case reflect.Bool:
+		return keysetValue{Type: keysetBool, Value: strconv.FormatBool(reflectValue.Bool())}, nil
+	case reflect.Invalid:
+		return keysetValue{}, errors.New("NULL value could not be used in cursor")
+	default:
+		return keysetValue{}, errors.New(stringFormatter.Format("value of type {0} could not be used in cursor",
+			reflect.TypeOf(value)))
+	}
+}
+
+// decode
+/* Function that converts typed cursor value to query parameter
+ */
+func (v keysetValue) decode() (interface{}, error) {
+	switch v.Type {
+	case keysetInt:
+		return strconv.ParseInt(v.Value, 10, 64)
+	case keysetUint:
+		return strconv.ParseUint(v.Value, 10, 64)
+	case keysetFloat:
+		return strconv.ParseFloat(v.Value, 64)
+	case keysetString:
+		return v.Value, nil
+	case keysetBool:
+		return strconv.ParseBool(v.Value)
+	case keysetTime:
+		return time.Parse(time.RFC3339Nano, v.Value)
+	case keysetBytes:
+		return base64.StdEncoding.DecodeString(v.Value)
+	default:
+		return nil, errors.New(stringFormatter.Format("unknown value type \"{0}\"", v.Type))
+	}
+}
@@ -0,0 +1,151 @@
+package gorm
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"testing"
+	"time"
+)
+
+func TestKeysetPaginationCondition(t *testing.T) {
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)
+	columns := []KeysetColumn{{Column: "created_at"}, {Column: "users.id"}}
+	cursor := createTestKeysetCursor(t, Postgres, columns, map[string]interface{}{"created_at": createdAt, "id": 42})
+
+	pagination, err := NewKeysetPagination(Postgres, columns, cursor, 10)
+	assert.NoError(t, err)
+	condition, args := pagination.getCondition()
+	assert.Equal(t, "(\"created_at\", \"users\".\"id\") > (?, ?)", condition)
+	assert.Equal(t, []interface{}{createdAt, int64(42)}, args)
+
+	pagination, err = NewKeysetPagination(Mssql, columns, cursor, 10)
+	assert.NoError(t, err)
+	condition, args = pagination.getCondition()
+	assert.Equal(t, "(([created_at] > ?) OR ([created_at] = ? AND [users].[id] > ?))", condition)
+	assert.Equal(t, []interface{}{createdAt, createdAt, int64(42)}, args)
+
+	columns[0].Desc = true
+	pagination, err = NewKeysetPagination(Mysql, columns, cursor, 10)
+	assert.NoError(t, err)
+	condition, _ = pagination.getCondition()
+	assert.Equal(t, "((`created_at` < ?) OR (`created_at` = ? AND `users`.`id` > ?))", condition)
+}
+
+func TestKeysetPaginationInvalidCursor(t *testing.T) {
+	columns := []KeysetColumn{{Column: "id"}}
+	_, err := NewKeysetPagination(Postgres, columns, "not a cursor!", 10)
+	assert.True(t, errors.Is(err, ErrInvalidCursor))
+	// cursor of other sort columns
+	cursor := createTestKeysetCursor(t, Postgres, []KeysetColumn{{Column: "name"}, {Column: "id"}},
+		map[string]interface{}{"name": "a", "id": 1})
+	_, err = NewKeysetPagination(Postgres, columns, cursor, 10)
+	assert.True(t, errors.Is(err, ErrInvalidCursor))
+	_, err = NewKeysetPagination(Postgres, []KeysetColumn{{Column: "bad\x00column"}}, "", 10)
+	assert.True(t, errors.Is(err, ErrInvalidIdentifier))
+	_, err = NewKeysetPagination(Postgres, nil, "", 10)
+	assert.True(t, errors.Is(err, ErrInvalidKeysetPagination))
+}
+
+func TestKeysetPaginationPageWithInvalidRows(t *testing.T) {
+	pagination, err := NewKeysetPagination(Postgres, []KeysetColumn{{Column: "id"}}, "", 1)
+	assert.NoError(t, err)
+	_, err = pagination.Page(nil, []map[string]interface{}{{"id": 1}})
+	assert.True(t, errors.Is(err, ErrInvalidKeysetPagination))
+	// extra row means that there is a next page, its cursor requires id column
+	rows := []map[string]interface{}{{"name": "a"}, {"name": "b"}}
+	_, err = pagination.Page(nil, &rows)
+	assert.True(t, errors.Is(err, ErrInvalidKeysetPagination))
+}
+
+func TestSqliteKeysetPagination(t *testing.T) {
+	db, _ := CreateTestDb(t, Sqlite, &gorm.Config{}, nil)
+	prepareDatabase(db)
+	names := []string{"c", "a", "b", "a", "c", "b", "a"}
+	for _, name := range names {
+		assert.NoError(t, db.Create(&Role{Name: name}).Error)
+	}
+	columns := []KeysetColumn{{Column: "name", Desc: true}, {Column: "id"}}
+	expected := [][]uint{{1, 5, 3}, {6, 2, 4}, {7}}
+
+	cursor := ""
+	var pages []KeysetPage
+	for _, expectedIds := range expected {
+		roles, page := selectTestKeysetPage(t, db, columns, cursor)
+		assert.Equal(t, expectedIds, getRoleIds(roles))
+		pages = append(pages, page)
+		cursor = page.NextCursor
+	}
+	assert.Empty(t, pages[0].PrevCursor)
+	assert.Empty(t, pages[2].NextCursor)
+
+	// back from last page
+	roles, page := selectTestKeysetPage(t, db, columns, pages[2].PrevCursor)
+	assert.Equal(t, expected[1], getRoleIds(roles))
+	assert.Equal(t, pages[1].NextCursor, page.NextCursor)
+	roles, page = selectTestKeysetPage(t, db, columns, page.PrevCursor)
+	assert.Equal(t, expected[0], getRoleIds(roles))
+	assert.Empty(t, page.PrevCursor)
+	assert.NotEmpty(t, page.NextCursor)
+
+	// rows are not skipped when rows are inserted before current page
+	assert.NoError(t, db.Create(&Role{Name: "z"}).Error)
+	roles, _ = selectTestKeysetPage(t, db, columns, pages[0].NextCursor)
+	assert.Equal(t, expected[1], getRoleIds(roles))
+}
+
+func TestSqliteKeysetPaginationMaps(t *testing.T) {
+	db, _ := CreateTestDb(t, Sqlite, &gorm.Config{}, nil)
+	prepareDatabase(db)
+	for _, name := range []string{"a", "b", "c"} {
+		assert.NoError(t, db.Create(&Role{Name: name}).Error)
+	}
+	columns := []KeysetColumn{{Column: "id", Desc: true}}
+	pagination, err := NewKeysetPagination(Sqlite, columns, "", 2)
+	assert.NoError(t, err)
+	var rows []map[string]interface{}
+	assert.NoError(t, db.Table("roles").Scopes(pagination.Scope()).Find(&rows).Error)
+	page, err := pagination.Page(db, &rows)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(rows))
+	assert.Equal(t, "c", rows[0]["name"])
+
+	pagination, err = NewKeysetPagination(Sqlite, columns, page.NextCursor, 2)
+	assert.NoError(t, err)
+	rows = nil
+	assert.NoError(t, db.Table("roles").Scopes(pagination.Scope()).Find(&rows).Error)
+	page, err = pagination.Page(db, &rows)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(rows))
+	assert.Equal(t, "a", rows[0]["name"])
+	assert.Empty(t, page.NextCursor)
+	assert.NotEmpty(t, page.PrevCursor)
+}
+
+func selectTestKeysetPage(t *testing.T, db *gorm.DB, columns []KeysetColumn, cursor string) ([]Role, KeysetPage) {
+	pagination, err := NewKeysetPagination(Sqlite, columns, cursor, 3)
+	assert.NoError(t, err)
+	var roles []Role
+	assert.NoError(t, db.Scopes(pagination.Scope()).Find(&roles).Error)
+	page, err := pagination.Page(db, &roles)
+	assert.NoError(t, err)
+	return roles, page
+}
+
+func createTestKeysetCursor(t *testing.T, dialect SqlDialect, columns []KeysetColumn,
+	row map[string]interface{}) string {
+	pagination, err := NewKeysetPagination(dialect, columns, "", 1)
+	assert.NoError(t, err)
+	rows := []map[string]interface{}{row, row}
+	page, err := pagination.Page(nil, &rows)
+	assert.NoError(t, err)
+	return page.NextCursor
+}
+
+func getRoleIds(roles []Role) []uint {
+	ids := make([]uint, len(roles))
+	for i, role := range roles {
+		ids[i] = role.ID
+	}
+	return ids
+}
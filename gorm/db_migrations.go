@@ -0,0 +1,636 @@
+package gorm
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"github.com/wissance/stringFormatter"
+	g "gorm.io/gorm"
+	"hash/fnv"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sentinel errors of migrations that are returning (wrapped in MigrationError) by Migrator methods and LoadMigrations
+var (
+	ErrInvalidMigration          = errors.New("invalid migration")
+	ErrMigrationNotFound         = errors.New("migration not found")
+	ErrMigrationChecksumMismatch = errors.New("migration checksum mismatch")
+	ErrIrreversibleMigration     = errors.New("migration has no down step")
+	ErrMigrationLocked           = errors.New("migration lock is held by other process")
+	ErrMigrationFailed           = errors.New("migration failed")
+)
+
+const defaultMigrationsTable = "schema_migrations"
+const defaultMigrationLockTimeout = time.Minute
+
+// noTransactionMarker is a comment that disables transaction for sql migration if it is a first line of up/down file
+const noTransactionMarker = "-- gwuu:no-transaction"
+
+// migrationFileRegexp matches migration file names: {version}_{name}.up.sql or {version}_{name}.down.sql
+var migrationFileRegexp = regexp.MustCompile(`^(\d+)_([^.]+)\.(up|down)\.sql$`)
+
+// mssqlBatchSeparatorRegexp matches Mssql batch separator (GO on a separate line)
+var mssqlBatchSeparatorRegexp = regexp.MustCompile(`(?im)^\s*GO\s*;?\s*$`)
+
+// Migration is a single versioned schema change, it is either sql (UpSql / DownSql, see LoadMigrations) or Go
+// (Up / Down functions) migration, if both are set Go functions are used
+type Migration struct {
+	// Version is a unique positive migration number, migrations are applied in ascending order
+	Version int64
+	Name    string
+	UpSql   string
+	DownSql string
+	Up      func(tx *g.DB) error
+	Down    func(tx *g.DB) error
+	// NoTransaction disables transaction of up step for statements that can't run inside it (i.e. CREATE INDEX
+	// CONCURRENTLY)
+	NoTransaction bool
+	// NoTransactionDown disables transaction of down step
+	NoTransactionDown bool
+}
+
+// MigrationStatus is a state of migration that is returned by Migrator.Status
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt *time.Time
+	// ChecksumMismatch is true if applied migration sql was changed after it was applied
+	ChecksumMismatch bool
+	// Missing is true if migration was applied but it is not present in migrations list
+	Missing bool
+}
+
+// MigratorOptions is a set of Migrator settings, zero value of any field means that default is used
+type MigratorOptions struct {
+	// HistoryTable is a name of table where applied migrations are stored (schema_migrations by default)
+	HistoryTable string
+	// LockTimeout is a maximum time of waiting for migration lock (1 minute by default)
+	LockTimeout time.Duration
+}
+
+// MigrationError is an error of migration, it matches (errors.Is) Kind sentinel error, Err is an original error
+type MigrationError struct {
+	Version int64
+	Name    string
+	Kind    error
+	Err     error
+}
+
+func (e *MigrationError) Error() string {
+	msg := e.Kind.Error()
+	if e.Version > 0 || len(e.Name) > 0 {
+		msg = stringFormatter.Format("{0} (version: {1}, name: \"{2}\")", msg, e.Version, e.Name)
+	}
+	if e.Err != nil {
+		msg = msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *MigrationError) Unwrap() error {
+	return e.Err
+}
+
+func (e *MigrationError) Is(target error) bool {
+	return target != nil && target == e.Kind
+}
+
+// Migrator applies and rolls back migrations of one database, applied versions and checksums are stored in history
+// table, concurrent Migrators (i.e. several service instances) are serialized with database lock (advisory lock for
+// Postgres, GET_LOCK for Mysql, sp_getapplock for Mssql, Sqlite serializes writes itself)
+type Migrator struct {
+	dialect      SqlDialect
+	db           *g.DB
+	migrations   []Migration
+	historyTable string
+	lockTimeout  time.Duration
+}
+
+// migrationRecord is a row of history table
+type migrationRecord struct {
+	Version   int64     `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"size:255;not null"`
+	Checksum  string    `gorm:"size:64;not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+// LoadMigrations
+/* Function that loads sql migrations from directory of file system (i.e. embed.FS), every migration consists of two
+ * files {version}_{name}.up.sql and {version}_{name}.down.sql (down file is optional), other files are ignored.
+ * If first line of file is "-- gwuu:no-transaction" this step (up or down) is running without transaction (see
+ * NoTransaction and NoTransactionDown). Scripts are executed
+ * as is for Postgres and Sqlite, Mssql scripts are split into batches by GO lines, Mysql scripts are split into
+ * statements by ; (DELIMITER is not supported)
+ * Parameters:
+ *    - fsys - file system with migrations (embed.FS, os.DirFS ...)
+ *    - dir - directory with migrations ("." for root)
+ * Returns tuple of migrations sorted by version and error (MigrationError with ErrInvalidMigration kind if there are
+ * duplicate versions or down file without up file)
+ */
+func LoadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, &MigrationError{Kind: ErrInvalidMigration, Err: err}
+	}
+	migrations := map[int64]*Migration{}
+	for _, entry := range entries {
+		match := migrationFileRegexp.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		version, parseErr := strconv.ParseInt(match[1], 10, 64)
+		if parseErr != nil || version <= 0 {
+			return nil, &MigrationError{Name: entry.Name(), Kind: ErrInvalidMigration, Err: parseErr}
+		}
+		content, readErr := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if readErr != nil {
+			return nil, &MigrationError{Version: version, Name: match[2], Kind: ErrInvalidMigration, Err: readErr}
+		}
+		migration, ok := migrations[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			migrations[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, &MigrationError{Version: version, Name: match[2], Kind: ErrInvalidMigration,
+				Err: errors.New("version is used by migrations with different names: " + migration.Name)}
+		}
+		script := string(content)
+		noTransaction := strings.HasPrefix(strings.TrimSpace(script), noTransactionMarker)
+		if match[3] == "up" {
+			migration.UpSql = script
+			migration.NoTransaction = noTransaction
+		} else {
+			migration.DownSql = script
+			migration.NoTransactionDown = noTransaction
+		}
+	}
+	result := make([]Migration, 0, len(migrations))
+	for _, migration := range migrations {
+		if len(migration.UpSql) == 0 {
+			return nil, &MigrationError{Version: migration.Version, Name: migration.Name, Kind: ErrInvalidMigration,
+				Err: errors.New("up migration file is missing or empty")}
+		}
+		result = append(result, *migration)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Version < result[j].Version
+	})
+	return result, nil
+}
+
+// NewMigrator
+/* Function that creates Migrator of opened database (i.e. by OpenDb2)
+ * Parameters:
+ *    - dialect - string that represent using db driver inside gorm (see enum above)
+ *    - db - opened database
+ *    - migrations - sql (see LoadMigrations) and/or Go migrations in any order
+ *    - options - migrator options (could be nil)
+ * Returns tuple of Migrator and error (MigrationError with ErrInvalidMigration kind if versions are not unique or not
+ * positive or migration has no up step)
+ */
+func NewMigrator(dialect SqlDialect, db *g.DB, migrations []Migration, options *MigratorOptions) (*Migrator, error) {
+	if getSqlDriverName(dialect) == "" {
+		return nil, &MigrationError{Kind: ErrInvalidMigration, Err: ErrUnsupportedDialect}
+	}
+	migrator := Migrator{dialect: dialect, db: db, historyTable: defaultMigrationsTable,
+		lockTimeout: defaultMigrationLockTimeout}
+	if options != nil {
+		if len(options.HistoryTable) > 0 {
+			migrator.historyTable = options.HistoryTable
+		}
+		if options.LockTimeout > 0 {
+			migrator.lockTimeout = options.LockTimeout
+		}
+	}
+	err := ValidateIdentifier(dialect, migrator.historyTable)
+	if err != nil {
+		return nil, &MigrationError{Kind: ErrInvalidMigration, Err: err}
+	}
+	migrator.migrations = make([]Migration, len(migrations))
+	copy(migrator.migrations, migrations)
+	sort.Slice(migrator.migrations, func(i, j int) bool {
+		return migrator.migrations[i].Version < migrator.migrations[j].Version
+	})
+	for i, migration := range migrator.migrations {
+		var validationErr error
+		if migration.Version <= 0 {
+			validationErr = errors.New("version should be positive")
+		} else if i > 0 && migrator.migrations[i-1].Version == migration.Version {
+			validationErr = errors.New("version is not unique")
+		} else if migration.Up == nil && len(migration.UpSql) == 0 {
+			validationErr = errors.New("migration has no up step")
+		}
+		if validationErr != nil {
+			return nil, &MigrationError{Version: migration.Version, Name: migration.Name, Kind: ErrInvalidMigration,
+				Err: validationErr}
+		}
+	}
+	return &migrator, nil
+}
+
+// Up
+/* Function that applies all pending migrations
+ * Parameters:
+ *    - ctx - context that bounds migration
+ * Returns nil if all migrations were applied, otherwise MigrationError
+ */
+func (m *Migrator) Up(ctx context.Context) error {
+	if len(m.migrations) == 0 {
+		return nil
+	}
+	return m.MigrateTo(ctx, m.migrations[len(m.migrations)-1].Version)
+}
+
+// MigrateTo
+/* Function that migrates database to version: pending migrations with version less or equal to target are applied in
+ * ascending order, applied migrations with version greater than target are rolled back in descending order. Every
+ * migration is running in its own transaction (except Mysql, where DDL statements commit implicitly, and
+ * migrations with NoTransaction), before migration checksums of applied sql migrations are verified
+ * Parameters:
+ *    - ctx - context that bounds migration
+ *    - version - target version, 0 means roll back all migrations
+ * Returns nil if database was migrated, otherwise MigrationError (ErrMigrationNotFound if target version is unknown,
+ * ErrMigrationChecksumMismatch, ErrIrreversibleMigration, ErrMigrationLocked or ErrMigrationFailed)
+ */
+func (m *Migrator) MigrateTo(ctx context.Context, version int64) error {
+	if version != 0 && m.findMigration(version) == nil {
+		return &MigrationError{Version: version, Kind: ErrMigrationNotFound}
+	}
+	// all statements are executed on locked connection, therefore migration does not wait for other connection of
+	// pool (i.e. with MaxOpenConns = 1)
+	db, release, err := m.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	err = m.createHistoryTable(db)
+	if err != nil {
+		return err
+	}
+
+	applied, err := m.getAppliedMigrations(db)
+	if err != nil {
+		return err
+	}
+	for _, record := range applied {
+		migration := m.findMigration(record.Version)
+		if migration != nil && isChecksumMismatch(migration, record) {
+			return &MigrationError{Version: record.Version, Name: record.Name, Kind: ErrMigrationChecksumMismatch}
+		}
+	}
+	// roll back in descending order
+	for i := len(applied) - 1; i >= 0; i-- {
+		record := applied[i]
+		if record.Version <= version {
+			continue
+		}
+		migration := m.findMigration(record.Version)
+		if migration == nil {
+			return &MigrationError{Version: record.Version, Name: record.Name, Kind: ErrMigrationNotFound}
+		}
+		if migration.Down == nil && len(migration.DownSql) == 0 {
+			return &MigrationError{Version: record.Version, Name: record.Name, Kind: ErrIrreversibleMigration}
+		}
+		err = m.runMigration(db, migration, false)
+		if err != nil {
+			return err
+		}
+	}
+	appliedVersions := map[int64]bool{}
+	for _, record := range applied {
+		appliedVersions[record.Version] = true
+	}
+	for i := range m.migrations {
+		migration := &m.migrations[i]
+		if migration.Version > version || appliedVersions[migration.Version] {
+			continue
+		}
+		err = m.runMigration(db, migration, true)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Status
+/* Function that returns state of every migration (known and applied but missing in migrations list)
+ * Parameters:
+ *    - ctx - context that bounds reading of history table
+ * Returns tuple of statuses sorted by version and error
+ */
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := m.getAppliedMigrations(m.db.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	appliedRecords := map[int64]migrationRecord{}
+	for _, record := range applied {
+		appliedRecords[record.Version] = record
+	}
+	statuses := make([]MigrationStatus, 0, len(m.migrations))
+	for i := range m.migrations {
+		migration := &m.migrations[i]
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		record, ok := appliedRecords[migration.Version]
+		if ok {
+			appliedAt := record.AppliedAt
+			status.Applied = true
+			status.AppliedAt = &appliedAt
+			status.ChecksumMismatch = isChecksumMismatch(migration, record)
+			delete(appliedRecords, migration.Version)
+		}
+		statuses = append(statuses, status)
+	}
+	for _, record := range appliedRecords {
+		appliedAt := record.AppliedAt
+		statuses = append(statuses, MigrationStatus{Version: record.Version, Name: record.Name, Applied: true,
+			AppliedAt: &appliedAt, Missing: true})
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+	return statuses, nil
+}
+
+// findMigration
+/* Function that returns migration by version or nil if there is no such migration
+ */
+func (m *Migrator) findMigration(version int64) *Migration {
+	index := sort.Search(len(m.migrations), func(i int) bool {
+		return m.migrations[i].Version >= version
+	})
+	if index < len(m.migrations) && m.migrations[index].Version == version {
+		return &m.migrations[index]
+	}
+	return nil
+}
+
+// createHistoryTable
+/* Function that creates history table if it does not exist
+ */
+func (m *Migrator) createHistoryTable(db *g.DB) error {
+	err := db.Table(m.historyTable).AutoMigrate(&migrationRecord{})
+	if err != nil {
+		return &MigrationError{Kind: ErrMigrationFailed, Err: err}
+	}
+	return nil
+}
+
+// getAppliedMigrations
+/* Function that reads history table ordered by version, if history table does not exist there are no applied
+ * migrations
+ */
+func (m *Migrator) getAppliedMigrations(db *g.DB) ([]migrationRecord, error) {
+	var applied []migrationRecord
+	if !db.Migrator().HasTable(m.historyTable) {
+		return applied, nil
+	}
+	err := db.Table(m.historyTable).Order("version").Find(&applied).Error
+	if err != nil {
+		return nil, &MigrationError{Kind: ErrMigrationFailed, Err: err}
+	}
+	return applied, nil
+}
+
+// runMigration
+/* Function that runs up or down step of migration and adds or removes history table record in one transaction (if it
+ * is possible)
+ */
+func (m *Migrator) runMigration(db *g.DB, migration *Migration, up bool) error {
+	step := func(tx *g.DB) error {
+		var stepErr error
+		if up {
+			stepErr = m.runStep(tx, migration.Up, migration.UpSql)
+		} else {
+			stepErr = m.runStep(tx, migration.Down, migration.DownSql)
+		}
+		if stepErr != nil {
+			return stepErr
+		}
+		if up {
+			record := migrationRecord{Version: migration.Version, Name: migration.Name,
+				Checksum: getMigrationChecksum(migration), AppliedAt: time.Now().UTC()}
+			return tx.Table(m.historyTable).Create(&record).Error
+		}
+		return tx.Table(m.historyTable).Where("version = ?", migration.Version).Delete(&migrationRecord{}).Error
+	}
+	noTransaction := migration.NoTransaction
+	if !up {
+		noTransaction = migration.NoTransactionDown
+	}
+	var err error
+	if noTransaction || m.dialect == Mysql {
+		err = step(db)
+	} else {
+		err = db.Transaction(step)
+	}
+	if err != nil {
+		return &MigrationError{Version: migration.Version, Name: migration.Name, Kind: ErrMigrationFailed, Err: err}
+	}
+	return nil
+}
+
+// runStep
+/* Function that runs Go function if it is set, otherwise sql script
+ */
+func (m *Migrator) runStep(tx *g.DB, fn func(tx *g.DB) error, script string) error {
+	if fn != nil {
+		return fn(tx)
+	}
+	for _, statement := range splitSqlScript(m.dialect, script) {
+		err := tx.Exec(statement).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lock
+/* Function that acquires migration lock on dedicated connection (session level locks belong to connection), Sqlite
+ * has no such locks, only connection is taken
+ * Returns tuple of database that runs all statements on locked connection, release function and error
+ * (MigrationError with ErrMigrationLocked kind on timeout)
+ */
+func (m *Migrator) lock(ctx context.Context) (*g.DB, func(), error) {
+	noop := func() {}
+	sqlDb, err := m.db.DB()
+	if err != nil {
+		return nil, noop, &MigrationError{Kind: ErrMigrationFailed, Err: err}
+	}
+	conn, err := sqlDb.Conn(ctx)
+	if err != nil {
+		return nil, noop, &MigrationError{Kind: ErrMigrationFailed, Err: err}
+	}
+	lockedDb := m.db.WithContext(ctx)
+	// session config is a copy, therefore pool of m.db is not changed
+	lockedDb.Config.ConnPool = conn
+	lockedDb.Statement.ConnPool = conn
+	if m.dialect == Sqlite {
+		return lockedDb, func() {
+			_ = conn.Close()
+		}, nil
+	}
+	lockName := "gwuu_migrations_" + m.historyTable
+	lockCtx, cancel := context.WithTimeout(ctx, m.lockTimeout)
+	defer cancel()
+	var result int64
+	switch m.dialect {
+	case Postgres:
+		err = conn.QueryRowContext(lockCtx, "SELECT 1 FROM pg_advisory_lock($1)", getLockKey(lockName)).Scan(&result)
+	case Mysql:
+		if len(lockName) > 64 {
+			lockName = lockName[:64]
+		}
+		err = conn.QueryRowContext(lockCtx, "SELECT GET_LOCK(?, ?)", lockName,
+			int64(m.lockTimeout.Seconds())).Scan(&result)
+	case Mssql:
+		err = conn.QueryRowContext(lockCtx, "DECLARE @result INT; EXEC @result = sp_getapplock @Resource = @p1, "+
+			"@LockMode = 'Exclusive', @LockOwner = 'Session', @LockTimeout = @p2; "+
+			"SELECT CASE WHEN @result >= 0 THEN 1 ELSE 0 END", lockName, m.lockTimeout.Milliseconds()).Scan(&result)
+	}
+	if err == nil && result != 1 {
+		err = ErrMigrationLocked
+	}
+	if err != nil {
+		_ = conn.Close()
+		if errors.Is(err, ErrMigrationLocked) || (errors.Is(lockCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil) {
+			return nil, noop, &MigrationError{Kind: ErrMigrationLocked, Err: err}
+		}
+		return nil, noop, &MigrationError{Kind: ErrMigrationFailed, Err: err}
+	}
+	return lockedDb, func() {
+		releaseLock(m.dialect, conn, lockName)
+	}, nil
+}
+
+// releaseLock
+/* Function that releases migration lock and returns dedicated connection to pool
+ */
+func releaseLock(dialect SqlDialect, conn *sql.Conn, lockName string) {
+	releaseCtx, cancel := context.WithTimeout(context.Background(), defaultMigrationLockTimeout)
+	defer cancel()
+	switch dialect {
+	case Postgres:
+		_, _ = conn.ExecContext(releaseCtx, "SELECT pg_advisory_unlock($1)", getLockKey(lockName))
+	case Mysql:
+		_, _ = conn.ExecContext(releaseCtx, "SELECT RELEASE_LOCK(?)", lockName)
+	case Mssql:
+		_, _ = conn.ExecContext(releaseCtx, "EXEC sp_releaseapplock @Resource = @p1, @LockOwner = 'Session'", lockName)
+	}
+	_ = conn.Close()
+}
+
+// getLockKey
+/* Function that converts lock name to Postgres advisory lock key
+ */
+func getLockKey(lockName string) int64 {
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte(lockName))
+	return int64(hash.Sum64())
+}
+
+// getMigrationChecksum
+/* Function that calculates sha256 of up sql script, Go migrations have no checksum
+ */
+func getMigrationChecksum(migration *Migration) string {
+	if migration.Up != nil {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(migration.UpSql))
+	return hex.EncodeToString(sum[:])
+}
+
+// isChecksumMismatch
+/* Function that checks if applied sql migration was changed, Go migrations are not checked
+ */
+func isChecksumMismatch(migration *Migration, record migrationRecord) bool {
+	checksum := getMigrationChecksum(migration)
+	return len(checksum) > 0 && len(record.Checksum) > 0 && checksum != record.Checksum
+}
+
+// splitSqlScript
+/* Function that splits migration script into parts that are executed separately: Mssql - batches separated by GO
+ * lines, Mysql - statements separated by ; (driver executes only one statement without multiStatements parameter),
+ * Postgres and Sqlite drivers execute whole script. Empty parts are omitted
+ */
+func splitSqlScript(dialect SqlDialect, script string) []string {
+	var parts []string
+	switch dialect {
+	case Mssql:
+		parts = mssqlBatchSeparatorRegexp.Split(script, -1)
+	case Mysql:
+		parts = splitSqlStatements(script)
+	default:
+		parts = []string{script}
+	}
+	statements := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if !isEmptySql(part) {
+			statements = append(statements, strings.TrimSpace(part))
+		}
+	}
+	return statements
+}
+
+// splitSqlStatements
+/* Function that splits script by ; that are not inside quotes ('', "", ``) or comments (--, #, / * * /)
+ */
+func splitSqlStatements(script string) []string {
+	statements := make([]string, 0)
+	start := 0
+	for i := 0; i < len(script); i++ {
+		switch c := script[i]; {
+		case c == '\'' || c == '"' || c == '`':
+			for i++; i < len(script); i++ {
+				if script[i] == '\\' && c != '`' {
+					i++
+				} else if script[i] == c {
+					break
+				}
+			}
+		case c == '#' || c == '-' && i+1 < len(script) && script[i+1] == '-':
+			for i < len(script) && script[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < len(script) && script[i+1] == '*':
+			end := strings.Index(script[i+2:], "*/")
+			if end < 0 {
+				i = len(script)
+			} else {
+				i = i + 2 + end + 1
+			}
+		case c == ';':
+			statements = append(statements, script[start:i])
+			start = i + 1
+		}
+	}
+	if start < len(script) {
+		statements = append(statements, script[start:])
+	}
+	return statements
+}
+
+// isEmptySql
+/* Function that checks that sql contains only whitespaces and line comments
+ */
+func isEmptySql(sqlText string) bool {
+	for _, line := range strings.Split(sqlText, "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) > 0 && !strings.HasPrefix(line, "--") && !strings.HasPrefix(line, "#") {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,206 @@
+package gorm
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+var testMigrationsFs = fstest.MapFS{
+	"migrations/1_create_roles.up.sql": {Data: []byte("CREATE TABLE roles (id INTEGER PRIMARY KEY, name TEXT NOT NULL, " +
+		"code TEXT);")},
+	"migrations/1_create_roles.down.sql": {Data: []byte("DROP TABLE roles;")},
+	"migrations/2_create_users.up.sql": {Data: []byte("CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT);\n" +
+		"INSERT INTO roles (name, code) VALUES ('admin', 'ADM');")},
+	"migrations/2_create_users.down.sql": {Data: []byte("DELETE FROM roles WHERE code = 'ADM';\nDROP TABLE users;")},
+	"migrations/README.md":               {Data: []byte("not a migration")},
+}
+
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := LoadMigrations(testMigrationsFs, "migrations")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(migrations))
+	assert.Equal(t, int64(1), migrations[0].Version)
+	assert.Equal(t, "create_roles", migrations[0].Name)
+	assert.Equal(t, "DROP TABLE roles;", migrations[0].DownSql)
+	assert.Equal(t, int64(2), migrations[1].Version)
+
+	_, err = LoadMigrations(fstest.MapFS{"1_only_down.down.sql": {Data: []byte("SELECT 1;")}}, ".")
+	assert.True(t, errors.Is(err, ErrInvalidMigration))
+	_, err = LoadMigrations(fstest.MapFS{"1_first.up.sql": {Data: []byte("SELECT 1;")},
+		"1_second.up.sql": {Data: []byte("SELECT 2;")}}, ".")
+	assert.True(t, errors.Is(err, ErrInvalidMigration))
+
+	migrations, err = LoadMigrations(fstest.MapFS{"3_index.up.sql": {Data: []byte(noTransactionMarker + "\nSELECT 1;")}}, ".")
+	assert.NoError(t, err)
+	assert.True(t, migrations[0].NoTransaction)
+	assert.False(t, migrations[0].NoTransactionDown)
+	// marker of down file does not affect up step
+	migrations, err = LoadMigrations(fstest.MapFS{"3_index.up.sql": {Data: []byte("SELECT 1;")},
+		"3_index.down.sql": {Data: []byte(noTransactionMarker + "\nSELECT 2;")}}, ".")
+	assert.NoError(t, err)
+	assert.False(t, migrations[0].NoTransaction)
+	assert.True(t, migrations[0].NoTransactionDown)
+}
+
+func TestNewMigratorValidatesMigrations(t *testing.T) {
+	db := openSqliteMigrationsDb(t)
+	_, err := NewMigrator(Sqlite, db, []Migration{{Version: 1, UpSql: "SELECT 1"}, {Version: 1, UpSql: "SELECT 2"}}, nil)
+	assert.True(t, errors.Is(err, ErrInvalidMigration))
+	_, err = NewMigrator(Sqlite, db, []Migration{{Version: 0, UpSql: "SELECT 1"}}, nil)
+	assert.True(t, errors.Is(err, ErrInvalidMigration))
+	_, err = NewMigrator(Sqlite, db, []Migration{{Version: 1}}, nil)
+	assert.True(t, errors.Is(err, ErrInvalidMigration))
+	_, err = NewMigrator(Sqlite, db, nil, &MigratorOptions{HistoryTable: "bad\x00table"})
+	assert.True(t, errors.Is(err, ErrInvalidIdentifier))
+}
+
+func TestSqliteMigrateUpAndDown(t *testing.T) {
+	ctx := context.Background()
+	db := openSqliteMigrationsDb(t)
+	migrations, err := LoadMigrations(testMigrationsFs, "migrations")
+	assert.NoError(t, err)
+	migrations = append(migrations, Migration{Version: 3, Name: "seed_user_role",
+		Up: func(tx *gorm.DB) error {
+			return tx.Exec("INSERT INTO roles (name, code) VALUES (?, ?)", "user", "USR").Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Exec("DELETE FROM roles WHERE code = ?", "USR").Error
+		}})
+	migrator, err := NewMigrator(Sqlite, db, migrations, nil)
+	assert.NoError(t, err)
+
+	statuses, err := migrator.Status(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(statuses))
+	assert.False(t, statuses[0].Applied)
+
+	assert.NoError(t, migrator.Up(ctx))
+	var rolesNumber int64
+	assert.NoError(t, db.Table("roles").Count(&rolesNumber).Error)
+	assert.Equal(t, int64(2), rolesNumber)
+	statuses, err = migrator.Status(ctx)
+	assert.NoError(t, err)
+	for _, status := range statuses {
+		assert.True(t, status.Applied)
+		assert.NotNil(t, status.AppliedAt)
+		assert.False(t, status.ChecksumMismatch)
+	}
+	// repeated Up does nothing
+	assert.NoError(t, migrator.Up(ctx))
+
+	assert.NoError(t, migrator.MigrateTo(ctx, 1))
+	assert.False(t, db.Migrator().HasTable("users"))
+	assert.NoError(t, db.Table("roles").Count(&rolesNumber).Error)
+	assert.Equal(t, int64(0), rolesNumber)
+	statuses, err = migrator.Status(ctx)
+	assert.NoError(t, err)
+	assert.True(t, statuses[0].Applied)
+	assert.False(t, statuses[1].Applied)
+	assert.False(t, statuses[2].Applied)
+
+	assert.NoError(t, migrator.MigrateTo(ctx, 0))
+	assert.False(t, db.Migrator().HasTable("roles"))
+	assert.True(t, errors.Is(migrator.MigrateTo(ctx, 42), ErrMigrationNotFound))
+}
+
+func TestSqliteMigrateWithSingleConnectionPool(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	db, err := OpenDb2Context(ctx, Sqlite, filepath.Join(t.TempDir(), "migrations.db"), true, true, &gorm.Config{},
+		nil, &OpenOptions{Pool: &PoolOptions{MaxOpenConns: 1}})
+	assert.NoError(t, err)
+	defer CloseDb(db)
+	migrations, err := LoadMigrations(testMigrationsFs, "migrations")
+	assert.NoError(t, err)
+	migrator, err := NewMigrator(Sqlite, db, migrations, nil)
+	assert.NoError(t, err)
+	// migrations run on connection that is held by migrator
+	assert.NoError(t, migrator.Up(ctx))
+	assert.NoError(t, migrator.MigrateTo(ctx, 1))
+	assert.True(t, db.Migrator().HasTable("roles"))
+}
+
+func TestSqliteMigrationChecksumMismatch(t *testing.T) {
+	ctx := context.Background()
+	db := openSqliteMigrationsDb(t)
+	migrations := []Migration{{Version: 1, Name: "create_roles", UpSql: "CREATE TABLE roles (id INTEGER PRIMARY KEY);"}}
+	migrator, err := NewMigrator(Sqlite, db, migrations, &MigratorOptions{HistoryTable: "gwuu_migrations"})
+	assert.NoError(t, err)
+	assert.NoError(t, migrator.Up(ctx))
+	assert.True(t, db.Migrator().HasTable("gwuu_migrations"))
+
+	migrations[0].UpSql = "CREATE TABLE roles (id INTEGER PRIMARY KEY, name TEXT);"
+	migrations = append(migrations, Migration{Version: 2, Name: "create_users", UpSql: "CREATE TABLE users (id INTEGER);"})
+	migrator, err = NewMigrator(Sqlite, db, migrations, &MigratorOptions{HistoryTable: "gwuu_migrations"})
+	assert.NoError(t, err)
+	err = migrator.Up(ctx)
+	assert.True(t, errors.Is(err, ErrMigrationChecksumMismatch))
+	assert.False(t, db.Migrator().HasTable("users"))
+	statuses, err := migrator.Status(ctx)
+	assert.NoError(t, err)
+	assert.True(t, statuses[0].ChecksumMismatch)
+	// changed migration is not rolled back too
+	assert.True(t, errors.Is(migrator.MigrateTo(ctx, 0), ErrMigrationChecksumMismatch))
+}
+
+func TestSqliteFailedMigrationIsRolledBack(t *testing.T) {
+	ctx := context.Background()
+	db := openSqliteMigrationsDb(t)
+	migrations := []Migration{{Version: 1, Name: "broken",
+		UpSql: "CREATE TABLE roles (id INTEGER PRIMARY KEY);\nINSERT INTO missing_table VALUES (1);"}}
+	migrator, err := NewMigrator(Sqlite, db, migrations, nil)
+	assert.NoError(t, err)
+	err = migrator.Up(ctx)
+	assert.True(t, errors.Is(err, ErrMigrationFailed))
+	var migrationErr *MigrationError
+	assert.True(t, errors.As(err, &migrationErr))
+	assert.Equal(t, int64(1), migrationErr.Version)
+	assert.False(t, db.Migrator().HasTable("roles"))
+	statuses, err := migrator.Status(ctx)
+	assert.NoError(t, err)
+	assert.False(t, statuses[0].Applied)
+}
+
+func TestSqliteIrreversibleMigration(t *testing.T) {
+	ctx := context.Background()
+	db := openSqliteMigrationsDb(t)
+	migrator, err := NewMigrator(Sqlite, db, []Migration{{Version: 1, Name: "create_roles",
+		UpSql: "CREATE TABLE roles (id INTEGER PRIMARY KEY);"}}, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, migrator.Up(ctx))
+	assert.True(t, errors.Is(migrator.MigrateTo(ctx, 0), ErrIrreversibleMigration))
+}
+
+func TestSplitSqlScript(t *testing.T) {
+	mysqlScript := "CREATE TABLE roles (id INT, name VARCHAR(10) DEFAULT 'a;b');\n" +
+		"-- comment; with semicolon\n" +
+		"INSERT INTO roles VALUES (1, \"it\\\"s;\"); /* block; comment */\n" +
+		"INSERT INTO `ro;les` VALUES (2, 'x');\n"
+	assert.Equal(t, []string{
+		"CREATE TABLE roles (id INT, name VARCHAR(10) DEFAULT 'a;b')",
+		"-- comment; with semicolon\nINSERT INTO roles VALUES (1, \"it\\\"s;\")",
+		"/* block; comment */\nINSERT INTO `ro;les` VALUES (2, 'x')",
+	}, splitSqlScript(Mysql, mysqlScript))
+
+	mssqlScript := "CREATE TABLE roles (id INT);\nGO\nCREATE PROCEDURE p AS SELECT 1; SELECT 2;\ngo\n"
+	assert.Equal(t, []string{"CREATE TABLE roles (id INT);", "CREATE PROCEDURE p AS SELECT 1; SELECT 2;"},
+		splitSqlScript(Mssql, mssqlScript))
+
+	assert.Equal(t, []string{"SELECT 1; SELECT 2;"}, splitSqlScript(Postgres, "SELECT 1; SELECT 2;\n"))
+}
+
+func openSqliteMigrationsDb(t *testing.T) *gorm.DB {
+	cfg := gorm.Config{}
+	db, err := OpenDb2WithError(Sqlite, filepath.Join(t.TempDir(), "sqlite_gwuu_migrations.db"), true, true, &cfg, nil)
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		CloseDb(db)
+	})
+	return db
+}
@@ -0,0 +1,264 @@
+package gorm
+
+import (
+	"database/sql"
+	"errors"
+	mssql "github.com/denisenkom/go-mssqldb"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidSessionOption is returned if session option could not be applied to dialect (Mssql SET option or value is
+// not allowed, option is not supported by Mssql)
+var ErrInvalidSessionOption = errors.New("invalid session option")
+
+// Mssql SET options are concatenated into SessionInitSQL, therefore names are identifiers and values are ON, OFF,
+// integers or string literals only
+var (
+	mssqlSetOptionRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	mssqlSetValueRegexp  = regexp.MustCompile(`^(?i:ON|OFF|-?[0-9]+|N?'(?:[^']|'')*')$`)
+)
+
+// OpenOptions is a set of additional options of OpenDbContext, OpenDb2Context and CreateRandomDbContext functions,
+// nil value of any field means that it is not applied
+type OpenOptions struct {
+	Pool    *PoolOptions
+	Session *SessionOptions
+	Retry   *RetryPolicy
+	// VerifyCollation enables comparison of existing database collation with passed one when database existence is
+	// checked (see OpenDb2Context)
+	VerifyCollation bool
+}
+
+// PoolOptions is a set of database/sql connection pool limits, zero value of any field means that driver default is used
+type PoolOptions struct {
+	// MaxOpenConns is a maximum number of open connections (sql.DB.SetMaxOpenConns)
+	MaxOpenConns int
+	// MaxIdleConns is a maximum number of idle connections (sql.DB.SetMaxIdleConns), negative value means no idle connections
+	MaxIdleConns int
+	// ConnMaxLifetime is a maximum time connection may be reused (sql.DB.SetConnMaxLifetime)
+	ConnMaxLifetime time.Duration
+	// ConnMaxIdleTime is a maximum time connection may be idle (sql.DB.SetConnMaxIdleTime)
+	ConnMaxIdleTime time.Duration
+}
+
+// SessionOptions is a set of session settings that are applied to EVERY new connection of the pool
+/* Settings are applied in a dialect specific way:
+ *    - Postgres - as run-time parameters in connection string (they are sent in connection startup message)
+ *    - Mysql - as system variables in connection string (driver executes SET on every connection)
+ *    - Mssql - as SET statements of go-mssqldb Connector.SessionInitSQL (executed on every new or reset session),
+ *              Variables names should be identifiers and values should be ON, OFF, integers or string literals,
+ *              TimeZone and StatementTimeout are rejected because Mssql has no such session settings
+ *    - Sqlite - only Variables are applied as connection string parameters (i.e. _busy_timeout, _foreign_keys)
+ * Fields that are not applicable to the dialect are ignored
+ */
+type SessionOptions struct {
+	// Schema is a default schema: Postgres - it is placed first in search_path, Mysql - it replaces database name of
+	// connection string (schema is a synonym of database), Mssql - is not supported (default schema is a user property)
+	Schema string
+	// SearchPath is a Postgres search_path (comma separated list of schemas)
+	SearchPath string
+	// TimeZone is a Postgres timezone or Mysql time_zone, Mssql is not supported
+	TimeZone string
+	// StatementTimeout is a Postgres statement_timeout or Mysql max_execution_time (rounded to milliseconds), Mssql is
+	// not supported
+	StatementTimeout time.Duration
+	// SqlMode is a Mysql sql_mode
+	SqlMode string
+	// Variables are any other settings: Postgres run-time parameters, Mysql system variables (values are SQL literals,
+	// i.e. "'READ-COMMITTED'"), Mssql SET options (i.e. "LOCK_TIMEOUT": "5000", "ANSI_NULLS": "ON"), Sqlite connection
+	// string parameters
+	Variables map[string]string
+}
+
+// applyPoolOptions
+/* Function that applies connection pool limits to sql.DB
+ * Parameters:
+ *    - sqlDb - connection pool
+ *    - pool - pool options (could be nil)
+ */
+func applyPoolOptions(sqlDb *sql.DB, pool *PoolOptions) {
+	if pool == nil {
+		return
+	}
+	if pool.MaxOpenConns != 0 {
+		sqlDb.SetMaxOpenConns(pool.MaxOpenConns)
+	}
+	if pool.MaxIdleConns != 0 {
+		sqlDb.SetMaxIdleConns(pool.MaxIdleConns)
+	}
+	if pool.ConnMaxLifetime != 0 {
+		sqlDb.SetConnMaxLifetime(pool.ConnMaxLifetime)
+	}
+	if pool.ConnMaxIdleTime != 0 {
+		sqlDb.SetConnMaxIdleTime(pool.ConnMaxIdleTime)
+	}
+}
+
+// applySessionOptions
+/* Function that adds session settings to connection string (Postgres, Mysql, Sqlite) or creates Mssql session init
+ * statements
+ * Parameters:
+ *    - dialect - string that represent using db driver inside gorm (see enum above)
+ *    - connStr - full connection string
+ *    - session - session options (could be nil)
+ * Returns tuple of connection string, Mssql session init SQL and error (if connStr could not be parsed or session option
+ * is not valid, see ErrInvalidSessionOption)
+ */
+func applySessionOptions(dialect SqlDialect, connStr string, session *SessionOptions) (string, string, error) {
+	if session == nil {
+		return connStr, "", nil
+	}
+	if dialect == Mssql {
+		sessionInitSql, err := createMssqlSessionInitSql(session)
+		if err != nil {
+			return "", "", err
+		}
+		return connStr, sessionInitSql, nil
+	}
+	cfg, err := ParseConnectionString(dialect, connStr)
+	if err != nil {
+		return "", "", err
+	}
+	if len(session.Schema) > 0 && (dialect == Postgres || dialect == Mysql) {
+		err = ValidateIdentifier(dialect, session.Schema)
+		if err != nil {
+			return "", "", err
+		}
+	}
+	for k, v := range getSessionParams(dialect, session) {
+		cfg.Params[k] = v
+	}
+	if dialect == Mysql && len(session.Schema) > 0 {
+		cfg.DbName = session.Schema
+	}
+	connStr, err = cfg.ConnectionString()
+	return connStr, "", err
+}
+
+// getSessionParams
+/* Function that converts session options to connection string parameters of dialect
+ * Parameters:
+ *    - dialect - string that represent using db driver inside gorm (see enum above)
+ *    - session - session options
+ * Returns parameters map
+ */
+func getSessionParams(dialect SqlDialect, session *SessionOptions) map[string]string {
+	params := map[string]string{}
+	switch dialect {
+	case Postgres:
+		searchPath := make([]string, 0, 2)
+		if len(session.Schema) > 0 {
+			// schema name is validated in applySessionOptions
+			quotedSchema, _ := QuoteIdentifier(Postgres, session.Schema)
+			searchPath = append(searchPath, quotedSchema)
+		}
+		if len(session.SearchPath) > 0 {
+			searchPath = append(searchPath, session.SearchPath)
+		}
+		if len(searchPath) > 0 {
+			params["search_path"] = strings.Join(searchPath, ",")
+		}
+		if len(session.TimeZone) > 0 {
+			params["timezone"] = session.TimeZone
+		}
+		if session.StatementTimeout > 0 {
+			params["statement_timeout"] = strconv.FormatInt(session.StatementTimeout.Milliseconds(), 10)
+		}
+	case Mysql:
+		if len(session.TimeZone) > 0 {
+			params["time_zone"] = quoteSqlString(session.TimeZone)
+		}
+		if session.StatementTimeout > 0 {
+			params["max_execution_time"] = strconv.FormatInt(session.StatementTimeout.Milliseconds(), 10)
+		}
+		if len(session.SqlMode) > 0 {
+			params["sql_mode"] = quoteSqlString(session.SqlMode)
+		}
+	}
+	for k, v := range session.Variables {
+		params[k] = v
+	}
+	return params
+}
+
+// createMssqlSessionInitSql
+/* Function that creates SET statements from session Variables in sorted order, names and values are validated because
+ * they are not escaped
+ * Parameters:
+ *    - session - session options
+ * Returns tuple of SQL text (empty if there are no Variables) and error (matches ErrInvalidSessionOption)
+ */
+func createMssqlSessionInitSql(session *SessionOptions) (string, error) {
+	if len(session.TimeZone) > 0 {
+		return "", newWrappedError(ErrInvalidSessionOption, nil, "Mssql does not support session TimeZone")
+	}
+	if session.StatementTimeout > 0 {
+		return "", newWrappedError(ErrInvalidSessionOption, nil, "Mssql does not support session StatementTimeout, "+
+			"use context deadline")
+	}
+	statements := make([]string, 0, len(session.Variables))
+	keys := make([]string, 0, len(session.Variables))
+	for k := range session.Variables {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if !mssqlSetOptionRegexp.MatchString(k) {
+			return "", newWrappedError(ErrInvalidSessionOption, nil, "\"{0}\" is not a valid SET option name", k)
+		}
+		if !mssqlSetValueRegexp.MatchString(session.Variables[k]) {
+			return "", newWrappedError(ErrInvalidSessionOption, nil, "value of SET {0} should be ON, OFF, integer or "+
+				"string literal", k)
+		}
+		statements = append(statements, "SET "+k+" "+session.Variables[k]+";")
+	}
+	return strings.Join(statements, " "), nil
+}
+
+// openSqlDb
+/* Function that opens database/sql connection pool of dialect with session options
+ * Parameters:
+ *    - dialect - string that represent using db driver inside gorm (see enum above)
+ *    - connStr - full connection string
+ *    - openOptions - additional options (could be nil)
+ * Returns tuple of connection pool, connection string with session parameters and error
+ */
+func openSqlDb(dialect SqlDialect, connStr string, openOptions *OpenOptions) (*sql.DB, string, error) {
+	var session *SessionOptions
+	var pool *PoolOptions
+	if openOptions != nil {
+		session = openOptions.Session
+		pool = openOptions.Pool
+	}
+	connStr, sessionInitSql, err := applySessionOptions(dialect, connStr, session)
+	if err != nil {
+		return nil, "", err
+	}
+	var sqlDb *sql.DB
+	if len(sessionInitSql) > 0 {
+		connector, connectorErr := mssql.NewConnector(connStr)
+		if connectorErr != nil {
+			return nil, "", connectorErr
+		}
+		connector.SessionInitSQL = sessionInitSql
+		sqlDb = sql.OpenDB(connector)
+	} else {
+		sqlDb, err = sql.Open(getSqlDriverName(dialect), connStr)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	applyPoolOptions(sqlDb, pool)
+	return sqlDb, connStr, nil
+}
+
+// quoteSqlString
+/* Function that makes SQL string literal from value (single quotes, inner quotes are doubled)
+ */
+func quoteSqlString(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
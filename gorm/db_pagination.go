@@ -0,0 +1,101 @@
+package gorm
+
+import (
+	"context"
+	"database/sql"
+	g "gorm.io/gorm"
+)
+
+const maximumPageSize = 100
+
+// PageOptions is a page size limits of GetPage
+type PageOptions struct {
+	// DefaultSize is used when requested size is less than 1, 25 if not set
+	DefaultSize int
+	// MaximumSize limits requested size, 100 if not set
+	MaximumSize int
+}
+
+// PageResult is a page of query result with page metadata
+type PageResult[T any] struct {
+	Items      []T
+	Total      int64
+	Page       int
+	Size       int
+	TotalPages int
+}
+
+// GetPage
+/* Function that selects page of rows and total number of rows that match query conditions in one transaction
+ * (REPEATABLE READ read only transaction for Postgres and Mysql, therefore total is consistent with items)
+ * Parameters:
+ *    - db - gorm.DB with query conditions (Where, Joins, Order ...), model is T if it is not set
+ *    - page - number of page starting from 1, page less than 1 is the first one
+ *    - size - number of rows to select, if it is less than 1 default size is used, size is limited by maximum size
+ *    - options - page size limits (could be nil)
+ * Returns tuple of page result and error
+ */
+func GetPage[T any](db *g.DB, page int, size int, options *PageOptions) (*PageResult[T], error) {
+	return GetPageContext[T](context.Background(), db, page, size, options)
+}
+
+// GetPageContext
+/* Function that does same as GetPage but queries could be cancelled or bounded by ctx deadline
+ * Parameters:
+ *    - ctx - context that bounds queries
+ *    - other parameters are the same as in GetPage
+ * Returns tuple of page result and error
+ */
+func GetPageContext[T any](ctx context.Context, db *g.DB, page int, size int, options *PageOptions) (*PageResult[T],
+	error) {
+	page, size = normalizePage(page, size, options)
+	result := PageResult[T]{Items: []T{}, Page: page, Size: size}
+	var txOptions *sql.TxOptions
+	dialector := db.Dialector.Name()
+	if dialector == "postgres" || dialector == "mysql" {
+		txOptions = &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+	}
+	if db.Statement.Model == nil && db.Statement.Table == "" {
+		db = db.Model(new(T))
+	}
+	err := db.WithContext(ctx).Transaction(func(tx *g.DB) error {
+		err := tx.Session(&g.Session{}).Count(&result.Total).Error
+		if err != nil {
+			return err
+		}
+		offset := (page - 1) * size
+		if int64(offset) >= result.Total {
+			return nil
+		}
+		return tx.Session(&g.Session{}).Offset(offset).Limit(size).Find(&result.Items).Error
+	}, txOptions)
+	if err != nil {
+		return nil, err
+	}
+	result.TotalPages = int((result.Total + int64(size) - 1) / int64(size))
+	return &result, nil
+}
+
+// normalizePage
+/* Function that replaces page less than 1 with first page, size less than 1 with default size and limits size
+ */
+func normalizePage(page int, size int, options *PageOptions) (int, int) {
+	defaultSize := defaultPageSize
+	maximumSize := maximumPageSize
+	if options != nil && options.DefaultSize > 0 {
+		defaultSize = options.DefaultSize
+	}
+	if options != nil && options.MaximumSize > 0 {
+		maximumSize = options.MaximumSize
+	}
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 {
+		size = defaultSize
+	}
+	if size > maximumSize {
+		size = maximumSize
+	}
+	return page, size
+}
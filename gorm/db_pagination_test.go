@@ -0,0 +1,53 @@
+package gorm
+
+import (
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"testing"
+)
+
+func TestNormalizePage(t *testing.T) {
+	page, size := normalizePage(0, 0, nil)
+	assert.Equal(t, 1, page)
+	assert.Equal(t, defaultPageSize, size)
+	_, size = normalizePage(2, 1000, nil)
+	assert.Equal(t, maximumPageSize, size)
+	_, size = normalizePage(2, 0, &PageOptions{DefaultSize: 10})
+	assert.Equal(t, 10, size)
+	_, size = normalizePage(2, 1000, &PageOptions{MaximumSize: 500})
+	assert.Equal(t, 500, size)
+}
+
+func TestSqliteGetPage(t *testing.T) {
+	db, _ := CreateTestDb(t, Sqlite, &gorm.Config{}, nil)
+	prepareDatabase(db)
+	for _, name := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+		assert.NoError(t, db.Create(&Role{Name: name}).Error)
+	}
+
+	result, err := GetPage[Role](db.Where("name <> ?", "g").Order("name DESC"), 2, 4, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(6), result.Total)
+	assert.Equal(t, 2, result.TotalPages)
+	assert.Equal(t, 2, result.Page)
+	assert.Equal(t, 4, result.Size)
+	assert.Equal(t, 2, len(result.Items))
+	assert.Equal(t, "b", result.Items[0].Name)
+
+	// size is limited, page out of range is empty
+	result, err = GetPage[Role](db, 3, 10, &PageOptions{MaximumSize: 3})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, result.Size)
+	assert.Equal(t, 1, len(result.Items))
+	result, err = GetPage[Role](db, 10, 0, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, defaultPageSize, result.Size)
+	assert.Equal(t, 1, result.TotalPages)
+	assert.NotNil(t, result.Items)
+	assert.Equal(t, 0, len(result.Items))
+
+	// Paginate passes size 0 as is, page size limits are applied only by GetPage
+	var roles []Role
+	assert.NoError(t, db.Scopes(Paginate(1, 0)).Find(&roles).Error)
+	assert.Equal(t, 7, len(roles))
+}
@@ -0,0 +1,277 @@
+package gorm
+
+import (
+	"context"
+	"errors"
+	g "gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+	"reflect"
+)
+
+// ErrNotSoftDeletable is returned by Repository.Restore if model has no gorm.DeletedAt field
+var ErrNotSoftDeletable = errors.New("model does not support soft delete")
+
+// Repository is a generic repository of model T (i.e. User) with CRUD operations, it does not hold state except
+// database, therefore it could be created per request or transaction (WithTx)
+type Repository[T any] struct {
+	db *g.DB
+}
+
+// NewRepository
+/* Function that creates repository of model T
+ * Parameters:
+ *    - db - opened database or transaction
+ * Returns repository address
+ */
+func NewRepository[T any](db *g.DB) *Repository[T] {
+	return &Repository[T]{db: db}
+}
+
+// WithTx
+/* Function that creates repository of same model that works inside transaction
+ * Parameters:
+ *    - tx - transaction (i.e. argument of db.Transaction function)
+ * Returns repository address
+ */
+func (r *Repository[T]) WithTx(tx *g.DB) *Repository[T] {
+	return &Repository[T]{db: tx}
+}
+
+// GetById
+/* Function that selects model by primary key, soft deleted models are not selected
+ * Parameters:
+ *    - ctx - context that bounds query
+ *    - id - primary key value, it is always passed as query parameter
+ * Returns tuple of model address and error (gorm.ErrRecordNotFound if there is no model with id)
+ */
+func (r *Repository[T]) GetById(ctx context.Context, id interface{}) (*T, error) {
+	condition, err := r.getPrimaryKeyCondition(id)
+	if err != nil {
+		return nil, err
+	}
+	var item T
+	err = r.db.WithContext(ctx).Where(condition).First(&item).Error
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// List
+/* Function that selects page of models (see Paginate)
+ * Parameters:
+ *    - ctx - context that bounds query
+ *    - page - number of page starting from 1
+ *    - size - number of rows to select
+ *    - scopes - query scopes (i.e. ParseFilter and ParseSort scopes)
+ * Returns tuple of models and error
+ */
+func (r *Repository[T]) List(ctx context.Context, page int, size int, scopes ...func(*g.DB) *g.DB) ([]T, error) {
+	items := []T{}
+	err := r.db.WithContext(ctx).Scopes(scopes...).Scopes(Paginate(page, size)).Find(&items).Error
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// ListPage
+/* Function that selects page of models with total number of models (see GetPage)
+ * Parameters:
+ *    - ctx - context that bounds queries
+ *    - page - number of page starting from 1
+ *    - size - number of rows to select
+ *    - options - page size limits (could be nil)
+ *    - scopes - query scopes (i.e. ParseFilter and ParseSort scopes)
+ * Returns tuple of page result and error
+ */
+func (r *Repository[T]) ListPage(ctx context.Context, page int, size int, options *PageOptions,
+	scopes ...func(*g.DB) *g.DB) (*PageResult[T], error) {
+	return GetPageContext[T](ctx, r.db.Model(new(T)).Scopes(scopes...), page, size, options)
+}
+
+// Create
+/* Function that inserts model, generated primary key and timestamps are set to item
+ * Parameters:
+ *    - ctx - context that bounds query
+ *    - item - model address
+ * Returns error
+ */
+func (r *Repository[T]) Create(ctx context.Context, item *T) error {
+	return r.db.WithContext(ctx).Create(item).Error
+}
+
+// Update
+/* Function that updates all fields of model (including zero values) except creation time, primary key of item should
+ * be set, unlike gorm Save model is not inserted if it does not exist. Mysql reports changed (not matched) rows by
+ * default, therefore if no row was affected model existence is checked
+ * Parameters:
+ *    - ctx - context that bounds query
+ *    - item - model address
+ * Returns error (gorm.ErrRecordNotFound if there is no model with item primary key)
+ */
+func (r *Repository[T]) Update(ctx context.Context, item *T) error {
+	modelSchema, err := r.getSchema()
+	if err != nil {
+		return err
+	}
+	var omit []string
+	for _, field := range modelSchema.Fields {
+		if field.AutoCreateTime > 0 {
+			omit = append(omit, field.DBName)
+		}
+	}
+	result := r.db.WithContext(ctx).Model(item).Select("*").Omit(omit...).Updates(item)
+	var id interface{}
+	if modelSchema.PrioritizedPrimaryField != nil {
+		id, _ = modelSchema.PrioritizedPrimaryField.ValueOf(reflect.ValueOf(item).Elem())
+	}
+	return r.getUpdateError(ctx, result, id)
+}
+
+// UpdateFields
+/* Function that updates only passed fields of model (partial update), if no row was affected (i.e. Mysql reports only
+ * changed rows) model existence is checked
+ * Parameters:
+ *    - ctx - context that bounds query
+ *    - id - primary key value
+ *    - fields - map of field (or column) names and values, or struct where non-zero fields are updated
+ * Returns error (gorm.ErrRecordNotFound if there is no model with id)
+ */
+func (r *Repository[T]) UpdateFields(ctx context.Context, id interface{}, fields interface{}) error {
+	condition, err := r.getPrimaryKeyCondition(id)
+	if err != nil {
+		return err
+	}
+	result := r.db.WithContext(ctx).Model(new(T)).Where(condition).Updates(fields)
+	return r.getUpdateError(ctx, result, id)
+}
+
+// Delete
+/* Function that deletes model, model with gorm.DeletedAt field (gorm.Model) is soft deleted
+ * Parameters:
+ *    - ctx - context that bounds query
+ *    - id - primary key value
+ * Returns error (gorm.ErrRecordNotFound if there is no model with id)
+ */
+func (r *Repository[T]) Delete(ctx context.Context, id interface{}) error {
+	condition, err := r.getPrimaryKeyCondition(id)
+	if err != nil {
+		return err
+	}
+	result := r.db.WithContext(ctx).Where(condition).Delete(new(T))
+	return getAffectedError(result)
+}
+
+// Restore
+/* Function that restores soft deleted model
+ * Parameters:
+ *    - ctx - context that bounds query
+ *    - id - primary key value
+ * Returns error (ErrNotSoftDeletable if model has no gorm.DeletedAt field, gorm.ErrRecordNotFound if there is no
+ * deleted model with id)
+ */
+func (r *Repository[T]) Restore(ctx context.Context, id interface{}) error {
+	modelSchema, err := r.getSchema()
+	if err != nil {
+		return err
+	}
+	var deletedAt *schema.Field
+	for _, field := range modelSchema.Fields {
+		if field.FieldType == reflect.TypeOf(g.DeletedAt{}) {
+			deletedAt = field
+			break
+		}
+	}
+	if deletedAt == nil {
+		return ErrNotSoftDeletable
+	}
+	condition, err := r.getPrimaryKeyCondition(id)
+	if err != nil {
+		return err
+	}
+	result := r.db.WithContext(ctx).Unscoped().Model(new(T)).Where(condition).
+		Where(clause.Neq{Column: clause.Column{Table: clause.CurrentTable, Name: deletedAt.DBName}, Value: nil}).
+		Update(deletedAt.DBName, nil)
+	return getAffectedError(result)
+}
+
+// Exists
+/* Function that checks whether model with primary key exists (soft deleted models are not counted)
+ * Parameters:
+ *    - ctx - context that bounds query
+ *    - id - primary key value
+ * Returns tuple of check result and error
+ */
+func (r *Repository[T]) Exists(ctx context.Context, id interface{}) (bool, error) {
+	condition, err := r.getPrimaryKeyCondition(id)
+	if err != nil {
+		return false, err
+	}
+	var count int64
+	err = r.db.WithContext(ctx).Model(new(T)).Where(condition).Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// getSchema
+/* Function that parses model T schema (schemas are cached by gorm)
+ */
+func (r *Repository[T]) getSchema() (*schema.Schema, error) {
+	stmt := g.Statement{DB: r.db}
+	err := stmt.Parse(new(T))
+	if err != nil {
+		return nil, err
+	}
+	return stmt.Schema, nil
+}
+
+// getPrimaryKeyCondition
+/* Function that creates condition on primary key column, unlike db.First(&item, id) string id is not treated as sql
+ */
+func (r *Repository[T]) getPrimaryKeyCondition(id interface{}) (clause.Expression, error) {
+	modelSchema, err := r.getSchema()
+	if err != nil {
+		return nil, err
+	}
+	if modelSchema.PrioritizedPrimaryField == nil {
+		return nil, g.ErrPrimaryKeyRequired
+	}
+	return clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: modelSchema.PrioritizedPrimaryField.DBName},
+		Value: id}, nil
+}
+
+// getUpdateError
+/* Function that returns update error or gorm.ErrRecordNotFound if update did not affect any row and model with id does
+ * not exist (update that does not change values affects no rows on Mysql without clientFoundRows)
+ */
+func (r *Repository[T]) getUpdateError(ctx context.Context, result *g.DB, id interface{}) error {
+	err := getAffectedError(result)
+	if !errors.Is(err, g.ErrRecordNotFound) || id == nil {
+		return err
+	}
+	exists, existsErr := r.Exists(ctx, id)
+	if existsErr != nil {
+		return existsErr
+	}
+	if exists {
+		return nil
+	}
+	return err
+}
+
+// getAffectedError
+/* Function that returns query error or gorm.ErrRecordNotFound if query did not affect any row
+ */
+func getAffectedError(result *g.DB) error {
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return g.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package gorm
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"testing"
+)
+
+// Team is a soft deletable model (Role embeds jinzhu gorm Model that is not soft deleted by gorm v2)
+type Team struct {
+	gorm.Model
+	Name string `gorm:"size:128"`
+}
+
+type Tag struct {
+	Code string `gorm:"primaryKey;size:32"`
+	Name string `gorm:"size:128"`
+}
+
+func TestSqliteRepository(t *testing.T) {
+	ctx := context.Background()
+	db, _ := CreateTestDb(t, Sqlite, &gorm.Config{}, nil)
+	assert.NoError(t, db.AutoMigrate(&Team{}))
+	repository := NewRepository[Team](db)
+
+	team := Team{Name: "admin"}
+	assert.NoError(t, repository.Create(ctx, &team))
+	assert.NotZero(t, team.ID)
+	for _, name := range []string{"developer", "guest"} {
+		assert.NoError(t, repository.Create(ctx, &Team{Name: name}))
+	}
+	loaded, err := repository.GetById(ctx, team.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "admin", loaded.Name)
+	_, err = repository.GetById(ctx, 42)
+	assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+
+	// full update keeps creation time
+	createdAt := loaded.CreatedAt
+	loaded.CreatedAt = createdAt.AddDate(-1, 0, 0)
+	loaded.Name = "administrator"
+	assert.NoError(t, repository.Update(ctx, loaded))
+	loaded, err = repository.GetById(ctx, team.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "administrator", loaded.Name)
+	assert.True(t, createdAt.Equal(loaded.CreatedAt))
+	assert.True(t, errors.Is(repository.Update(ctx, &Team{Model: gorm.Model{ID: 42}, Name: "x"}),
+		gorm.ErrRecordNotFound))
+
+	// partial update
+	assert.NoError(t, repository.UpdateFields(ctx, team.ID, map[string]interface{}{"name": "root"}))
+	loaded, err = repository.GetById(ctx, team.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "root", loaded.Name)
+	assert.True(t, errors.Is(repository.UpdateFields(ctx, 42, map[string]interface{}{"name": "x"}),
+		gorm.ErrRecordNotFound))
+
+	fields := map[string]FilterField{"name": {Column: "name"}}
+	sort, err := ParseSort(Sqlite, "-name", fields)
+	assert.NoError(t, err)
+	teams, err := repository.List(ctx, 1, 2, sort)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(teams))
+	assert.Equal(t, "root", teams[0].Name)
+	page, err := repository.ListPage(ctx, 2, 2, nil, sort)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), page.Total)
+	assert.Equal(t, "developer", page.Items[0].Name)
+
+	// soft delete and restore
+	assert.NoError(t, repository.Delete(ctx, team.ID))
+	exists, err := repository.Exists(ctx, team.ID)
+	assert.NoError(t, err)
+	assert.False(t, exists)
+	assert.True(t, errors.Is(repository.Delete(ctx, team.ID), gorm.ErrRecordNotFound))
+	assert.NoError(t, repository.Restore(ctx, team.ID))
+	exists, err = repository.Exists(ctx, team.ID)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.True(t, errors.Is(repository.Restore(ctx, team.ID), gorm.ErrRecordNotFound))
+}
+
+func TestSqliteRepositoryUpdateWithoutAffectedRows(t *testing.T) {
+	ctx := context.Background()
+	db, _ := CreateTestDb(t, Sqlite, &gorm.Config{}, nil)
+	assert.NoError(t, db.AutoMigrate(&Team{}))
+	repository := NewRepository[Team](db)
+	team := Team{Name: "admin"}
+	assert.NoError(t, repository.Create(ctx, &team))
+	// Mysql without clientFoundRows reports zero affected rows when values are not changed
+	assert.NoError(t, db.Callback().Update().After("gorm:update").Register("test:found_rows",
+		func(db *gorm.DB) {
+			db.RowsAffected = 0
+		}))
+
+	assert.NoError(t, repository.Update(ctx, &team))
+	assert.NoError(t, repository.UpdateFields(ctx, team.ID, map[string]interface{}{"name": "admin"}))
+	assert.True(t, errors.Is(repository.Update(ctx, &Team{Model: gorm.Model{ID: 42}, Name: "x"}),
+		gorm.ErrRecordNotFound))
+	assert.True(t, errors.Is(repository.UpdateFields(ctx, 42, map[string]interface{}{"name": "x"}),
+		gorm.ErrRecordNotFound))
+}
+
+func TestSqliteRepositoryStringPrimaryKey(t *testing.T) {
+	ctx := context.Background()
+	db, _ := CreateTestDb(t, Sqlite, &gorm.Config{}, nil)
+	assert.NoError(t, db.AutoMigrate(&Tag{}))
+	repository := NewRepository[Tag](db)
+	assert.NoError(t, repository.Create(ctx, &Tag{Code: "go", Name: "Golang"}))
+	// string id is a value, not sql condition
+	_, err := repository.GetById(ctx, "1 = 1")
+	assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+	tag, err := repository.GetById(ctx, "go")
+	assert.NoError(t, err)
+	assert.Equal(t, "Golang", tag.Name)
+	assert.True(t, errors.Is(repository.Restore(ctx, "go"), ErrNotSoftDeletable))
+	// hard delete
+	assert.NoError(t, repository.Delete(ctx, "go"))
+	var tagsNumber int64
+	assert.NoError(t, db.Unscoped().Model(&Tag{}).Count(&tagsNumber).Error)
+	assert.Equal(t, int64(0), tagsNumber)
+}
+
+func TestSqliteRepositoryWithTx(t *testing.T) {
+	ctx := context.Background()
+	db, _ := CreateTestDb(t, Sqlite, &gorm.Config{}, nil)
+	prepareDatabase(db)
+	repository := NewRepository[Role](db)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		txRepository := repository.WithTx(tx)
+		createErr := txRepository.Create(ctx, &Role{Name: "admin"})
+		if createErr != nil {
+			return createErr
+		}
+		return errors.New("rollback")
+	})
+	assert.Error(t, err)
+	roles, err := repository.List(ctx, 1, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(roles))
+}
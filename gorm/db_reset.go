@@ -0,0 +1,253 @@
+package gorm
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"github.com/wissance/stringFormatter"
+	g "gorm.io/gorm"
+	"strings"
+)
+
+// resetTable is a row of tables query, Name is a quoted (and qualified) table name that could be used in statements
+type resetTable struct {
+	TableName string
+	Name      string
+}
+
+// mssqlIdentity is a row of Mssql identity columns query, ReseedValue is a value that makes next identity equal to seed
+type mssqlIdentity struct {
+	Name        string
+	ReseedValue int64
+}
+
+// ResetDatabase
+/* Function that removes all rows from user tables of database that is opened by db and resets identity sequences
+ * (auto increment counters), so database could be reused between tests instead of creating new one. Tables of current
+ * schema (Postgres, Mssql) or database (Mysql, Sqlite) are discovered from catalog:
+ *    - Postgres - all tables are truncated with one TRUNCATE ... RESTART IDENTITY statement (sequences owned by
+ *      tables are reset like with setval)
+ *    - Mysql - tables are truncated with FOREIGN_KEY_CHECKS disabled on dedicated connection, TRUNCATE resets
+ *      AUTO_INCREMENT
+ *    - Mssql - constraints are disabled (NOCHECK), rows are deleted, constraints are re-enabled and identities are
+ *      reseeded with DBCC CHECKIDENT in one transaction
+ *    - Sqlite - rows are deleted with deferred foreign keys and sqlite_sequence is cleared in one transaction
+ * Excluded tables keep their rows, if excluded table references truncated table with foreign key reset fails on
+ * Postgres, Mssql and Sqlite (with enabled foreign keys)
+ * Parameters:
+ *    - dialect - string that represent using db driver inside gorm (see enum above)
+ *    - db - opened database
+ *    - excludeTables - names of tables that should not be reset (i.e. migrations history, reference data)
+ * Returns nil if database was reset, otherwise DbError (ErrResetFailed)
+ */
+func ResetDatabase(dialect SqlDialect, db *g.DB, excludeTables ...string) error {
+	return ResetDatabaseContext(context.Background(), dialect, db, excludeTables...)
+}
+
+// ResetDatabaseContext
+/* Function that does same as ResetDatabase but reset could be cancelled or bounded by ctx deadline
+ * Parameters:
+ *    - ctx - context that bounds reset
+ *    - other parameters are the same as in ResetDatabase
+ * Returns nil if database was reset, otherwise DbError (ErrResetFailed)
+ */
+func ResetDatabaseContext(ctx context.Context, dialect SqlDialect, db *g.DB, excludeTables ...string) error {
+	query := getResetTablesQuery(dialect)
+	if len(query) == 0 {
+		return newSchemaError(ctx, ErrResetFailed, dialect, getDbNameOfDb(db), "", ErrUnsupportedDialect)
+	}
+	var allTables []resetTable
+	err := db.WithContext(ctx).Raw(query).Scan(&allTables).Error
+	if err != nil {
+		return newSchemaError(ctx, ErrResetFailed, dialect, getDbNameOfDb(db), "", err)
+	}
+	tables := make([]resetTable, 0, len(allTables))
+	for _, table := range allTables {
+		if !isExcludedTable(table.TableName, excludeTables) {
+			tables = append(tables, table)
+		}
+	}
+	if len(tables) == 0 {
+		return nil
+	}
+	switch dialect {
+	case Postgres:
+		names := make([]string, len(tables))
+		for i, table := range tables {
+			names[i] = table.Name
+		}
+		err = db.WithContext(ctx).Exec(stringFormatter.Format("TRUNCATE TABLE {0} RESTART IDENTITY",
+			strings.Join(names, ", "))).Error
+	case Mysql:
+		err = resetMysqlTables(ctx, db, tables)
+	case Mssql:
+		err = db.WithContext(ctx).Transaction(func(tx *g.DB) error {
+			return resetMssqlTables(tx, tables)
+		})
+	case Sqlite:
+		err = db.WithContext(ctx).Transaction(func(tx *g.DB) error {
+			return resetSqliteTables(tx, tables)
+		})
+	}
+	if err != nil {
+		return newSchemaError(ctx, ErrResetFailed, dialect, getDbNameOfDb(db), "", err)
+	}
+	return nil
+}
+
+// resetMysqlTables
+/* Function that truncates Mysql tables on dedicated connection because FOREIGN_KEY_CHECKS is a session variable, it is
+ * restored before connection returns to pool
+ */
+func resetMysqlTables(ctx context.Context, db *g.DB, tables []resetTable) error {
+	sqlDb, err := db.DB()
+	if err != nil {
+		return err
+	}
+	conn, err := sqlDb.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer func(conn *sql.Conn) {
+		// connection with disabled checks must not be reused
+		_, restoreErr := conn.ExecContext(context.Background(), "SET FOREIGN_KEY_CHECKS = 1")
+		if restoreErr != nil {
+			_ = conn.Raw(func(driverConn interface{}) error {
+				return driver.ErrBadConn
+			})
+		}
+		_ = conn.Close()
+	}(conn)
+	_, err = conn.ExecContext(ctx, "SET FOREIGN_KEY_CHECKS = 0")
+	if err != nil {
+		return err
+	}
+	for _, table := range tables {
+		_, err = conn.ExecContext(ctx, "TRUNCATE TABLE "+table.Name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resetMssqlTables
+/* Function that deletes rows of Mssql tables with disabled constraints (TRUNCATE is not allowed for tables that are
+ * referenced by foreign keys) and reseeds identities of tables that ever had rows
+ */
+func resetMssqlTables(tx *g.DB, tables []resetTable) error {
+	for _, table := range tables {
+		err := tx.Exec("ALTER TABLE " + table.Name + " NOCHECK CONSTRAINT ALL").Error
+		if err != nil {
+			return err
+		}
+	}
+	for _, table := range tables {
+		err := tx.Exec("DELETE FROM " + table.Name).Error
+		if err != nil {
+			return err
+		}
+	}
+	for _, table := range tables {
+		err := tx.Exec("ALTER TABLE " + table.Name + " WITH CHECK CHECK CONSTRAINT ALL").Error
+		if err != nil {
+			return err
+		}
+	}
+	var identities []mssqlIdentity
+	// identity of table without inserted rows (last_value is NULL) starts from seed after reseed, others from
+	// reseed value + increment
+	err := tx.Raw("SELECT QUOTENAME(OBJECT_SCHEMA_NAME(object_id)) + '.' + QUOTENAME(OBJECT_NAME(object_id)) AS name, " +
+		"CAST(seed_value AS BIGINT) - CAST(increment_value AS BIGINT) AS reseed_value FROM sys.identity_columns " +
+		"WHERE last_value IS NOT NULL AND OBJECT_SCHEMA_NAME(object_id) = SCHEMA_NAME()").Scan(&identities).Error
+	if err != nil {
+		return err
+	}
+	for _, identity := range identities {
+		if !containsResetTable(tables, identity.Name) {
+			continue
+		}
+		err = tx.Exec(stringFormatter.Format("DBCC CHECKIDENT ('{0}', RESEED, {1}) WITH NO_INFOMSGS",
+			strings.ReplaceAll(identity.Name, "'", "''"), identity.ReseedValue)).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resetSqliteTables
+/* Function that deletes rows of Sqlite tables (foreign keys are checked on commit when all tables are empty) and
+ * resets AUTOINCREMENT counters, rowid of other tables restarts from 1 for empty table
+ */
+func resetSqliteTables(tx *g.DB, tables []resetTable) error {
+	err := tx.Exec("PRAGMA defer_foreign_keys = ON").Error
+	if err != nil {
+		return err
+	}
+	names := make([]string, len(tables))
+	for i, table := range tables {
+		err = tx.Exec("DELETE FROM " + table.Name).Error
+		if err != nil {
+			return err
+		}
+		names[i] = table.TableName
+	}
+	var sequenceTablesNumber int64
+	err = tx.Raw("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'sqlite_sequence'").
+		Scan(&sequenceTablesNumber).Error
+	if err != nil || sequenceTablesNumber == 0 {
+		return err
+	}
+	return tx.Exec("DELETE FROM sqlite_sequence WHERE name IN ?", names).Error
+}
+
+// getResetTablesQuery
+/* Function that returns catalog query that selects user tables of current schema (database) with table_name and name
+ * (quoted) columns
+ * Parameters:
+ *     - dialect - string that represent using db driver inside gorm (see enum above)
+ * Returns query text or empty string for unsupported dialect
+ */
+func getResetTablesQuery(dialect SqlDialect) string {
+	switch dialect {
+	case Postgres:
+		return "SELECT tablename AS table_name, quote_ident(schemaname) || '.' || quote_ident(tablename) AS name " +
+			"FROM pg_tables WHERE schemaname = current_schema()"
+	case Mysql:
+		return "SELECT TABLE_NAME AS table_name, CONCAT('`', REPLACE(TABLE_NAME, '`', '``'), '`') AS name " +
+			"FROM information_schema.TABLES WHERE TABLE_SCHEMA = DATABASE() AND TABLE_TYPE = 'BASE TABLE'"
+	case Mssql:
+		return "SELECT name AS table_name, QUOTENAME(SCHEMA_NAME(schema_id)) + '.' + QUOTENAME(name) AS name " +
+			"FROM sys.tables WHERE is_ms_shipped = 0 AND schema_id = SCHEMA_ID()"
+	case Sqlite:
+		return "SELECT name AS table_name, '\"' || REPLACE(name, '\"', '\"\"') || '\"' AS name FROM sqlite_master " +
+			"WHERE type = 'table' AND name NOT LIKE 'sqlite_%'"
+	default:
+		return ""
+	}
+}
+
+// isExcludedTable
+/* Function that checks whether table name is in excluded tables list
+ */
+func isExcludedTable(table string, excludeTables []string) bool {
+	for _, excluded := range excludeTables {
+		if excluded == table {
+			return true
+		}
+	}
+	return false
+}
+
+// containsResetTable
+/* Function that checks whether tables contain table with quoted name
+ */
+func containsResetTable(tables []resetTable, name string) bool {
+	for _, table := range tables {
+		if table.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package gorm
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"testing"
+)
+
+func TestSqliteResetDatabase(t *testing.T) {
+	db, _ := CreateTestDb(t, Sqlite, &gorm.Config{}, nil)
+	prepareDatabase(db)
+	assert.NoError(t, db.Exec("CREATE TABLE counters (id INTEGER PRIMARY KEY AUTOINCREMENT, value INTEGER)").Error)
+	role := Role{Name: "admin"}
+	assert.NoError(t, db.Create(&role).Error)
+	for i := 0; i < 3; i++ {
+		user := User{UserName: "user", PasswordHash: "123", Profile: Profile{Name: "profile"}, Roles: []Role{role}}
+		assert.NoError(t, db.Create(&user).Error)
+		assert.NoError(t, db.Exec("INSERT INTO counters (value) VALUES (?)", i).Error)
+	}
+
+	assert.NoError(t, ResetDatabase(Sqlite, db, "roles"))
+	assertRowsNumber(t, db, "users", 0)
+	assertRowsNumber(t, db, "profiles", 0)
+	assertRowsNumber(t, db, "user_roles", 0)
+	assertRowsNumber(t, db, "counters", 0)
+	// excluded table keeps rows
+	assertRowsNumber(t, db, "roles", 1)
+
+	user := User{UserName: "user", PasswordHash: "123", Profile: Profile{Name: "profile"}}
+	assert.NoError(t, db.Create(&user).Error)
+	assert.Equal(t, uint(1), user.ID)
+	assert.NoError(t, db.Exec("INSERT INTO counters (value) VALUES (?)", 42).Error)
+	var counterId int64
+	assert.NoError(t, db.Raw("SELECT id FROM counters WHERE value = ?", 42).Scan(&counterId).Error)
+	assert.Equal(t, int64(1), counterId)
+}
+
+func TestPostgresResetDatabase(t *testing.T) {
+	db, _ := CreateTestDb(t, Postgres, &gorm.Config{}, nil)
+	prepareDatabase(db)
+	role := Role{Name: "admin"}
+	assert.NoError(t, db.Create(&role).Error)
+	user := User{UserName: "user", PasswordHash: "123", Profile: Profile{Name: "profile"}, Roles: []Role{role}}
+	assert.NoError(t, db.Create(&user).Error)
+
+	assert.NoError(t, ResetDatabase(Postgres, db))
+	assertRowsNumber(t, db, "roles", 0)
+	assertRowsNumber(t, db, "users", 0)
+	user = User{UserName: "user", PasswordHash: "123", Profile: Profile{Name: "profile"}}
+	assert.NoError(t, db.Create(&user).Error)
+	assert.Equal(t, uint(1), user.ID)
+	assert.Equal(t, uint(1), user.ProfileId)
+}
+
+func TestResetDatabaseUnsupportedDialect(t *testing.T) {
+	db, _ := CreateTestDb(t, Sqlite, &gorm.Config{}, nil)
+	err := ResetDatabase("oracle", db)
+	assert.True(t, errors.Is(err, ErrResetFailed))
+	assert.True(t, errors.Is(err, ErrUnsupportedDialect))
+}
+
+func assertRowsNumber(t *testing.T, db *gorm.DB, table string, expected int64) {
+	var rowsNumber int64
+	assert.NoError(t, db.Table(table).Count(&rowsNumber).Error)
+	assert.Equal(t, expected, rowsNumber, table)
+}
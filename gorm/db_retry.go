@@ -0,0 +1,211 @@
+package gorm
+
+import (
+	"context"
+	"errors"
+	mssql "github.com/denisenkom/go-mssqldb"
+	driverMysql "github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgconn"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const defaultRetryInitialBackoff = 500 * time.Millisecond
+const defaultRetryMultiplier = 2.0
+
+// RetryPolicy is a policy of waiting for database (i.e. when services and database start together in docker-compose)
+/* It is using by OpenDb2Context (and other Context open functions) via OpenOptions.Retry, whole open (check, create and
+ * open) is repeated while error is retryable (connection refused, server is starting up, too many connections), errors
+ * like authentication failure or missing database are permanent and returned immediately. WithTransaction uses it
+ * (TransactionOptions.Retry) to repeat transactions that failed with serialization failure or deadlock.
+ * Delay before attempt n (starting from 1 after first failed attempt) is:
+ *     min(InitialBackoff * Multiplier^(n-1), MaxBackoff) + Jitter * delay (or - Jitter / 2 * delay)
+ * jitter subtracts at most a half of Jitter, therefore delay is never close to 0
+ */
+type RetryPolicy struct {
+	// MaxAttempts is a maximum number of attempts, 0 means no limit (MaxElapsedTime or ctx deadline must be set then)
+	MaxAttempts int
+	// InitialBackoff is a delay after first failed attempt (500ms if not set)
+	InitialBackoff time.Duration
+	// MaxBackoff is a maximum delay between attempts, 0 means no limit
+	MaxBackoff time.Duration
+	// Multiplier is a backoff growth factor (2 if not set)
+	Multiplier float64
+	// Jitter is a fraction of delay (0..1) that is randomly added (or its half is subtracted) to spread attempts of
+	// many instances
+	Jitter float64
+	// MaxElapsedTime is an overall deadline of all attempts, 0 means no deadline except ctx
+	MaxElapsedTime time.Duration
+	// IsRetryable overrides errors classification, if nil IsRetryableDbError is using
+	IsRetryable func(err error) bool
+	// OnAttempt is called after every attempt (could be nil), i.e. for logging
+	OnAttempt func(attempt RetryAttempt)
+}
+
+// RetryAttempt is an information about single attempt that is passing to RetryPolicy.OnAttempt
+type RetryAttempt struct {
+	// Number is an attempt number starting from 1
+	Number int
+	// Err is an attempt error, nil if attempt succeeded
+	Err error
+	// Retryable is true if Err is retryable
+	Retryable bool
+	// Delay is a delay before next attempt, 0 if there will be no next attempt
+	Delay time.Duration
+}
+
+// IsRetryableDbError
+/* Function that checks whether database open error is transient, and it makes sense to repeat an attempt:
+ *    - network errors (connection refused / reset, timeouts, unexpected EOF) and temporary DNS failures, unknown host
+ *      (i.e. misspelled host name) is permanent
+ *    - Postgres: server is starting up or shutting down (57P01, 57P02, 57P03), connection exceptions (08xxx),
+ *      too many connections (53300)
+ *    - Mysql: too many connections (1040), server shutdown (1053), lost connection (2002, 2003, 2006, 2013)
+ *    - Mssql: tcp connection was not opened, server is starting up (4060 is not included because it means missing database)
+ * Authentication and permission failures, missing database, invalid connection string or identifier and ctx errors are
+ * permanent
+ * Parameters:
+ *    - err - error returned by open function
+ * Returns true if error is retryable
+ */
+func IsRetryableDbError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, ErrAuthenticationFailed) || errors.Is(err, ErrPermissionDenied) ||
+		errors.Is(err, ErrDatabaseNotFound) || errors.Is(err, ErrDatabaseExists) ||
+		errors.Is(err, ErrInvalidConnStr) || errors.Is(err, ErrInvalidIdentifier) || errors.Is(err, ErrUnsupportedDialect) {
+		return false
+	}
+	return isTransientConnectionError(err)
+}
+
+// isTransientConnectionError
+/* Function that recognizes network and driver errors that mean database server is not available now
+ */
+func isTransientConnectionError(err error) bool {
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNABORTED) ||
+		errors.Is(err, syscall.EHOSTUNREACH) || errors.Is(err, syscall.ENETUNREACH) || errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) || errors.Is(err, driverMysql.ErrInvalidConn) {
+		return true
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTimeout || dnsErr.IsTemporary
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return strings.HasPrefix(pgErr.Code, "08") || pgErr.Code == "57P01" || pgErr.Code == "57P02" ||
+			pgErr.Code == "57P03" || pgErr.Code == "53300"
+	}
+	var mysqlErr *driverMysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		switch mysqlErr.Number {
+		case 1040, 1053, 2002, 2003, 2006, 2013:
+			return true
+		}
+		return false
+	}
+	var mssqlErr mssql.Error
+	if errors.As(err, &mssqlErr) {
+		// 17 server is paused, 18401 server is in script upgrade mode, 40613 database unavailable (Azure)
+		return mssqlErr.Number == 17 || mssqlErr.Number == 18401 || mssqlErr.Number == 40613
+	}
+	// go-mssqldb doesn't wrap dial errors
+	return strings.Contains(err.Error(), "Unable to open tcp connection")
+}
+
+// getDelay
+/* Function that calculates delay before next attempt
+ * Parameters:
+ *    - attempt - number of failed attempt starting from 1
+ * Returns delay
+ */
+func (policy *RetryPolicy) getDelay(attempt int) time.Duration {
+	initialBackoff := policy.InitialBackoff
+	if initialBackoff <= 0 {
+		initialBackoff = defaultRetryInitialBackoff
+	}
+	multiplier := policy.Multiplier
+	if multiplier < 1 {
+		multiplier = defaultRetryMultiplier
+	}
+	delay := float64(initialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if policy.MaxBackoff > 0 && delay > float64(policy.MaxBackoff) {
+		delay = float64(policy.MaxBackoff)
+	}
+	if policy.Jitter > 0 {
+		jitter := math.Min(policy.Jitter, 1)
+		factor := 2*rand.Float64() - 1
+		if factor < 0 {
+			// with Jitter 1 delay could become 0 and retry would be a busy loop
+			factor = factor / 2
+		}
+		delay = delay + delay*jitter*factor
+	}
+	return time.Duration(delay)
+}
+
+// isRetryable
+/* Function that classifies error with IsRetryable of policy or IsRetryableDbError
+ */
+func (policy *RetryPolicy) isRetryable(err error) bool {
+	if policy.IsRetryable != nil {
+		return policy.IsRetryable(err)
+	}
+	return IsRetryableDbError(err)
+}
+
+// retry
+/* Function that calls action (open function or transaction) until it succeeded, returned permanent error, attempts were
+ * exhausted or deadline (ctx or MaxElapsedTime) exceeded
+ * Parameters:
+ *    - ctx - context that bounds all attempts
+ *    - policy - retry policy
+ *    - action - open function or transaction
+ * Returns result of last attempt, if deadline exceeded during waiting last attempt error is returned
+ */
+func retry[T any](ctx context.Context, policy *RetryPolicy, action func(ctx context.Context) (T, error)) (T, error) {
+	if policy.MaxElapsedTime > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, policy.MaxElapsedTime)
+		defer cancel()
+	}
+	attempt := 0
+	for {
+		attempt++
+		result, err := action(ctx)
+		retryable := err != nil && policy.isRetryable(err)
+		var delay time.Duration
+		if retryable && (policy.MaxAttempts <= 0 || attempt < policy.MaxAttempts) {
+			delay = policy.getDelay(attempt)
+			deadline, hasDeadline := ctx.Deadline()
+			if ctx.Err() != nil || (hasDeadline && time.Now().Add(delay).After(deadline)) {
+				delay = 0
+			}
+		}
+		if policy.OnAttempt != nil {
+			policy.OnAttempt(RetryAttempt{Number: attempt, Err: err, Retryable: retryable, Delay: delay})
+		}
+		if delay == 0 {
+			return result, err
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return result, err
+		case <-timer.C:
+		}
+	}
+}
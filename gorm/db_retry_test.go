@@ -0,0 +1,152 @@
+package gorm
+
+import (
+	"context"
+	"errors"
+	driverMysql "github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgconn"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Multiplier: 3}
+	assert.Equal(t, 100*time.Millisecond, policy.getDelay(1))
+	assert.Equal(t, 300*time.Millisecond, policy.getDelay(2))
+	assert.Equal(t, 900*time.Millisecond, policy.getDelay(3))
+	assert.Equal(t, time.Second, policy.getDelay(4))
+
+	policy = RetryPolicy{}
+	assert.Equal(t, defaultRetryInitialBackoff, policy.getDelay(1))
+	assert.Equal(t, 2*defaultRetryInitialBackoff, policy.getDelay(2))
+
+	policy = RetryPolicy{InitialBackoff: 100 * time.Millisecond, Jitter: 0.2}
+	for i := 0; i < 100; i++ {
+		delay := policy.getDelay(1)
+		assert.GreaterOrEqual(t, delay, 90*time.Millisecond)
+		assert.LessOrEqual(t, delay, 120*time.Millisecond)
+	}
+	policy = RetryPolicy{InitialBackoff: 100 * time.Millisecond, Jitter: 1}
+	for i := 0; i < 100; i++ {
+		delay := policy.getDelay(1)
+		assert.GreaterOrEqual(t, delay, 50*time.Millisecond)
+		assert.LessOrEqual(t, delay, 200*time.Millisecond)
+	}
+}
+
+func TestIsRetryableDbError(t *testing.T) {
+	connRefused := &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+	assert.True(t, IsRetryableDbError(newDbError(ErrOpenFailed, Postgres, "app", connRefused)))
+	assert.True(t, IsRetryableDbError(newDbError(ErrOpenFailed, Postgres, "app", &pgconn.PgError{Code: "57P03"})))
+	assert.True(t, IsRetryableDbError(newDbError(ErrOpenFailed, Mysql, "app", &driverMysql.MySQLError{Number: 1040})))
+	assert.True(t, IsRetryableDbError(errors.New("Unable to open tcp connection with host 'localhost:1433': dial tcp")))
+	dnsTemporary := &net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Name: "db.local", IsTemporary: true}}
+	assert.True(t, IsRetryableDbError(newDbError(ErrOpenFailed, Postgres, "app", dnsTemporary)))
+	dnsTimeout := &net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Name: "db.local", IsTimeout: true}}
+	assert.True(t, IsRetryableDbError(newDbError(ErrOpenFailed, Postgres, "app", dnsTimeout)))
+
+	assert.False(t, IsRetryableDbError(nil))
+	assert.False(t, IsRetryableDbError(newDbError(ErrOpenFailed, Postgres, "app", &pgconn.PgError{Code: "28P01"})))
+	assert.False(t, IsRetryableDbError(newDbError(ErrOpenFailed, Mysql, "app", &driverMysql.MySQLError{Number: 1045})))
+	assert.False(t, IsRetryableDbError(newDbError(ErrOpenFailed, Sqlite, "app.db", ErrDatabaseNotFound)))
+	assert.False(t, IsRetryableDbError(context.DeadlineExceeded))
+	// misspelled host will not be resolved by next attempt
+	dnsNotFound := &net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Name: "db.lcoal", IsNotFound: true}}
+	assert.False(t, IsRetryableDbError(newDbError(ErrOpenFailed, Postgres, "app", dnsNotFound)))
+	assert.False(t, IsConnectionError(dnsNotFound))
+	invalidAddr := &net.OpError{Op: "dial", Net: "tcp", Err: net.InvalidAddrError("bad address")}
+	assert.False(t, IsRetryableDbError(newDbError(ErrOpenFailed, Postgres, "app", invalidAddr)))
+}
+
+func TestOpenDbWithRetryWhenServerIsNotAvailable(t *testing.T) {
+	cfg := gorm.Config{}
+	// nobody listens on port 1
+	connStr := BuildConnectionString(Postgres, "127.0.0.1", 1, "pg_gwuu_examples", dbUser, dbPassword, "disable")
+	attempts := make([]RetryAttempt, 0)
+	openOptions := OpenOptions{Retry: &RetryPolicy{MaxAttempts: 3, InitialBackoff: 10 * time.Millisecond,
+		OnAttempt: func(attempt RetryAttempt) {
+			attempts = append(attempts, attempt)
+		}}}
+	db, err := OpenDb2Context(context.Background(), Postgres, connStr, true, true, &cfg, nil, &openOptions)
+	assert.Nil(t, db)
+	assert.True(t, errors.Is(err, syscall.ECONNREFUSED))
+	assert.Equal(t, 3, len(attempts))
+	for i, attempt := range attempts {
+		assert.Equal(t, i+1, attempt.Number)
+		assert.True(t, attempt.Retryable)
+		assert.Error(t, attempt.Err)
+	}
+	assert.Equal(t, 10*time.Millisecond, attempts[0].Delay)
+	assert.Equal(t, 20*time.Millisecond, attempts[1].Delay)
+	assert.Equal(t, time.Duration(0), attempts[2].Delay)
+}
+
+func TestOpenDbWithRetryStopsOnDeadline(t *testing.T) {
+	cfg := gorm.Config{}
+	connStr := BuildConnectionString(Postgres, "127.0.0.1", 1, "pg_gwuu_examples", dbUser, dbPassword, "disable")
+	attemptsNumber := 0
+	openOptions := OpenOptions{Retry: &RetryPolicy{InitialBackoff: 50 * time.Millisecond,
+		MaxElapsedTime: 300 * time.Millisecond, OnAttempt: func(attempt RetryAttempt) {
+			attemptsNumber++
+		}}}
+	start := time.Now()
+	db, err := OpenDb2Context(context.Background(), Postgres, connStr, true, true, &cfg, nil, &openOptions)
+	assert.Nil(t, db)
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), 2*time.Second)
+	assert.True(t, attemptsNumber >= 2)
+}
+
+func TestOpenDbWithRetryReturnsPermanentErrorImmediately(t *testing.T) {
+	cfg := gorm.Config{}
+	connStr := filepath.Join(t.TempDir(), "sqlite_gwuu_missing.db")
+	attemptsNumber := 0
+	openOptions := OpenOptions{Retry: &RetryPolicy{MaxAttempts: 5, InitialBackoff: 10 * time.Millisecond,
+		OnAttempt: func(attempt RetryAttempt) {
+			attemptsNumber++
+			assert.False(t, attempt.Retryable)
+		}}}
+	db, err := OpenDb2Context(context.Background(), Sqlite, connStr, false, true, &cfg, nil, &openOptions)
+	assert.Nil(t, db)
+	assert.True(t, errors.Is(err, ErrDatabaseNotFound))
+	assert.Equal(t, 1, attemptsNumber)
+}
+
+func TestRetryOpenOrCreateOpensDatabaseCreatedByPreviousAttempt(t *testing.T) {
+	connRefused := &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+	opened := &gorm.DB{}
+	var calls []bool
+	policy := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+	db, err := retryOpenOrCreate(context.Background(), &policy, true,
+		func(ctx context.Context, create bool) (*gorm.DB, error) {
+			calls = append(calls, create)
+			switch len(calls) {
+			case 1:
+				// database was created, but open failed
+				return nil, newDbError(ErrOpenFailed, Postgres, "app", connRefused)
+			case 2:
+				return nil, newDbError(ErrCreateFailed, Postgres, "app", &pgconn.PgError{Code: "42P04"})
+			default:
+				return opened, nil
+			}
+		})
+	assert.NoError(t, err)
+	assert.Same(t, opened, db)
+	assert.Equal(t, []bool{true, true, false}, calls)
+
+	// database that exists before first attempt is an error
+	calls = nil
+	_, err = retryOpenOrCreate(context.Background(), &policy, true,
+		func(ctx context.Context, create bool) (*gorm.DB, error) {
+			calls = append(calls, create)
+			return nil, newDbError(ErrCreateFailed, Postgres, "app", &pgconn.PgError{Code: "42P04"})
+		})
+	assert.True(t, errors.Is(err, ErrDatabaseExists))
+	assert.Equal(t, []bool{true}, calls)
+}
@@ -0,0 +1,335 @@
+package gorm
+
+import (
+	"context"
+	"github.com/wissance/stringFormatter"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/driver/sqlserver"
+	g "gorm.io/gorm"
+)
+
+const tmpSchemaNameTemplate = "wissance_tmp_schema_{0}"
+
+// mssqlSchemaObject is a row of Mssql schema objects query, Name is a quoted two-part name
+type mssqlSchemaObject struct {
+	ObjectType string
+	Name       string
+}
+
+// mssqlForeignKey is a row of Mssql foreign keys query, TableName is a quoted two-part name
+type mssqlForeignKey struct {
+	TableName      string
+	ConstraintName string
+}
+
+// CreateSchema
+/* Function that creates schema in database that is opened by db, this function does not require database level
+ * privileges (CREATE DATABASE) on Postgres and Mssql, for Mysql schema is a synonym of database therefore CREATE
+ * SCHEMA creates a new database. Sqlite is not supported
+ * Parameters:
+ *    - dialect - string that represent using db driver inside gorm (see enum above)
+ *    - db - opened database
+ *    - schema - schema name, it is validated and quoted (see QuoteIdentifier)
+ * Returns nil if schema was created, otherwise DbError (ErrSchemaCreateFailed), if schema already exists error
+ * matches ErrSchemaExists (Postgres) or ErrDatabaseExists (Mysql)
+ */
+func CreateSchema(dialect SqlDialect, db *g.DB, schema string) error {
+	return CreateSchemaContext(context.Background(), dialect, db, schema)
+}
+
+// CreateSchemaContext
+/* Function that does same as CreateSchema but CREATE SCHEMA could be cancelled or bounded by ctx deadline
+ * Parameters:
+ *    - ctx - context that bounds schema creation
+ *    - other parameters are the same as in CreateSchema
+ * Returns nil if schema was created, otherwise DbError
+ */
+func CreateSchemaContext(ctx context.Context, dialect SqlDialect, db *g.DB, schema string) error {
+	if dialect != Postgres && dialect != Mysql && dialect != Mssql {
+		return newSchemaError(ctx, ErrSchemaCreateFailed, dialect, getDbNameOfDb(db), schema, ErrUnsupportedDialect)
+	}
+	quotedSchema, err := QuoteIdentifier(dialect, schema)
+	if err != nil {
+		return newSchemaError(ctx, ErrSchemaCreateFailed, dialect, getDbNameOfDb(db), schema, err)
+	}
+	err = db.WithContext(ctx).Exec(stringFormatter.Format("CREATE SCHEMA {0}", quotedSchema)).Error
+	if err != nil {
+		return newSchemaError(ctx, ErrSchemaCreateFailed, dialect, getDbNameOfDb(db), schema, err)
+	}
+	return nil
+}
+
+// DropSchema
+/* Function that drops schema if it exists
+ *    - Postgres - DROP SCHEMA IF EXISTS ... CASCADE (or RESTRICT)
+ *    - Mysql - DROP DATABASE IF EXISTS (schema is a synonym of database), if cascade is false and schema contains
+ *      tables it is not dropped
+ *    - Mssql - if cascade is true, foreign keys that reference schema tables, views, procedures, functions, tables and
+ *      sequences of schema are dropped in one transaction before DROP SCHEMA IF EXISTS
+ * Sqlite is not supported
+ * Parameters:
+ *    - dialect - string that represent using db driver inside gorm (see enum above)
+ *    - db - opened database
+ *    - schema - schema name
+ *    - cascade - if true schema objects are dropped too, otherwise only empty schema could be dropped
+ * Returns nil if schema was dropped or does not exist, otherwise DbError (ErrSchemaDropFailed), if schema is not empty
+ * error matches ErrSchemaNotEmpty
+ */
+func DropSchema(dialect SqlDialect, db *g.DB, schema string, cascade bool) error {
+	return DropSchemaContext(context.Background(), dialect, db, schema, cascade)
+}
+
+// DropSchemaContext
+/* Function that does same as DropSchema but dropping could be cancelled or bounded by ctx deadline
+ * Parameters:
+ *    - ctx - context that bounds schema dropping
+ *    - other parameters are the same as in DropSchema
+ * Returns nil if schema was dropped or does not exist, otherwise DbError
+ */
+func DropSchemaContext(ctx context.Context, dialect SqlDialect, db *g.DB, schema string, cascade bool) error {
+	quotedSchema, err := QuoteIdentifier(dialect, schema)
+	if err != nil {
+		return newSchemaError(ctx, ErrSchemaDropFailed, dialect, getDbNameOfDb(db), schema, err)
+	}
+	db = db.WithContext(ctx)
+	switch dialect {
+	case Postgres:
+		behavior := "RESTRICT"
+		if cascade {
+			behavior = "CASCADE"
+		}
+		err = db.Exec(stringFormatter.Format("DROP SCHEMA IF EXISTS {0} {1}", quotedSchema, behavior)).Error
+	case Mysql:
+		err = dropMysqlSchema(db, quotedSchema, schema, cascade)
+	case Mssql:
+		err = db.Transaction(func(tx *g.DB) error {
+			if cascade {
+				dropErr := dropMssqlSchemaObjects(tx, schema)
+				if dropErr != nil {
+					return dropErr
+				}
+			}
+			return tx.Exec(stringFormatter.Format("DROP SCHEMA IF EXISTS {0}", quotedSchema)).Error
+		})
+	default:
+		err = ErrUnsupportedDialect
+	}
+	if err != nil {
+		return newSchemaError(ctx, ErrSchemaDropFailed, dialect, getDbNameOfDb(db), schema, err)
+	}
+	return nil
+}
+
+// SchemaExists
+/* Function that checks schema existence in catalog of database that is opened by db (pg_namespace, sys.schemas,
+ * information_schema.SCHEMATA for Mysql). Sqlite is not supported
+ * Parameters:
+ *    - dialect - string that represent using db driver inside gorm (see enum above)
+ *    - db - opened database
+ *    - schema - schema name
+ * Returns (true, nil) if schema exists, (false, nil) if it does not exist and (false, DbError) if check failed
+ */
+func SchemaExists(dialect SqlDialect, db *g.DB, schema string) (bool, error) {
+	return SchemaExistsContext(context.Background(), dialect, db, schema)
+}
+
+// SchemaExistsContext
+/* Function that does same as SchemaExists but catalog query could be cancelled or bounded by ctx deadline
+ * Parameters:
+ *    - ctx - context that bounds check
+ *    - other parameters are the same as in SchemaExists
+ * Returns (true, nil) if schema exists, (false, nil) if it does not exist and (false, DbError) if check failed
+ */
+func SchemaExistsContext(ctx context.Context, dialect SqlDialect, db *g.DB, schema string) (bool, error) {
+	query := getSchemaExistsQuery(dialect)
+	if len(query) == 0 {
+		return false, newSchemaError(ctx, ErrOpenFailed, dialect, getDbNameOfDb(db), schema, ErrUnsupportedDialect)
+	}
+	var count int64
+	err := db.WithContext(ctx).Raw(query, schema).Scan(&count).Error
+	if err != nil {
+		return false, newSchemaError(ctx, ErrOpenFailed, dialect, getDbNameOfDb(db), schema, err)
+	}
+	return count > 0, nil
+}
+
+// CreateRandomSchema
+/* Function that creates schema with random name in database and opens database with this schema as a default one (see
+ * SessionOptions.Schema), it is a variant of CreateRandomDb that gives isolation (i.e. for tests) without database
+ * level privileges. Only Postgres and Mysql are supported (Mysql schema is a database, therefore database
+ * privileges are required), Mssql is not supported because default schema of user could be changed only with
+ * ALTER USER that requires ALTER ANY USER permission
+ * Parameters:
+ *    - dialect - string that represent using db driver inside gorm (see enum above)
+ *    - connStr - connection string of existing database
+ *    - options - gorm config (from gorm.io/gorm NOT from github.com/jinzhu/gorm)
+ * Returns tuple of gorm.DB address of database context object and schema name, if schema creation or opening failed
+ * nil is returned
+ */
+func CreateRandomSchema(dialect SqlDialect, connStr string, options *g.Config) (*g.DB, string) {
+	db, schema, _ := CreateRandomSchemaContext(context.Background(), dialect, connStr, options, nil)
+	return db, schema
+}
+
+// CreateRandomSchemaContext
+/* Function that does same as CreateRandomSchema but returns error and connection to server and schema creation could
+ * be cancelled or bounded by ctx deadline, schema should be dropped with DropSchema (cascade)
+ * Parameters:
+ *    - ctx - context that bounds schema creation and opening
+ *    - openOptions - connection pool, session and retry options (could be nil), Session.Schema is replaced by created
+ *                    schema
+ *    - other parameters are the same as in CreateRandomSchema
+ * Returns tuple of gorm.DB address of database context object, schema name and error
+ */
+func CreateRandomSchemaContext(ctx context.Context, dialect SqlDialect, connStr string, options *g.Config,
+	openOptions *OpenOptions) (*g.DB, string, error) {
+	if dialect != Postgres && dialect != Mysql {
+		_, dbName := createSystemDbConnStr(dialect, &connStr)
+		return nil, "", newDbError(ErrSchemaCreateFailed, dialect, dbName, ErrUnsupportedDialect)
+	}
+	schema := createRandomDbName(dialect, tmpSchemaNameTemplate)
+	db, err := OpenDb2Context(ctx, dialect, connStr, false, false, options, nil, openOptions)
+	if err != nil {
+		return nil, "", err
+	}
+	err = CreateSchemaContext(ctx, dialect, db, schema)
+	CloseDb(db)
+	if err != nil {
+		return nil, "", err
+	}
+	db, err = OpenDb2Context(ctx, dialect, connStr, false, false, options, nil, withSessionSchema(openOptions, schema))
+	if err != nil {
+		return nil, "", err
+	}
+	return db, schema, nil
+}
+
+// withSessionSchema
+/* Function that copies open options and sets default schema of session
+ */
+func withSessionSchema(openOptions *OpenOptions, schema string) *OpenOptions {
+	schemaOptions := OpenOptions{}
+	session := SessionOptions{}
+	if openOptions != nil {
+		schemaOptions = *openOptions
+		if openOptions.Session != nil {
+			session = *openOptions.Session
+		}
+	}
+	session.Schema = schema
+	schemaOptions.Session = &session
+	return &schemaOptions
+}
+
+// dropMysqlSchema
+/* Function that drops Mysql schema (database), if cascade is false and schema contains tables error is returned
+ */
+func dropMysqlSchema(db *g.DB, quotedSchema string, schema string, cascade bool) error {
+	if !cascade {
+		var tablesNumber int64
+		err := db.Raw("SELECT COUNT(*) FROM information_schema.TABLES WHERE TABLE_SCHEMA = ?", schema).
+			Scan(&tablesNumber).Error
+		if err != nil {
+			return err
+		}
+		if tablesNumber > 0 {
+			return ErrSchemaNotEmpty
+		}
+	}
+	return db.Exec(stringFormatter.Format("DROP DATABASE IF EXISTS {0}", quotedSchema)).Error
+}
+
+// dropMssqlSchemaObjects
+/* Function that drops foreign keys that reference Mssql schema tables and then all views, procedures, functions, tables
+ * and sequences of schema (in this order)
+ */
+func dropMssqlSchemaObjects(tx *g.DB, schema string) error {
+	var foreignKeys []mssqlForeignKey
+	err := tx.Raw("SELECT QUOTENAME(OBJECT_SCHEMA_NAME(parent_object_id)) + '.' + "+
+		"QUOTENAME(OBJECT_NAME(parent_object_id)) AS table_name, QUOTENAME(name) AS constraint_name FROM sys.foreign_keys "+
+		"WHERE schema_id = SCHEMA_ID(?) OR OBJECT_SCHEMA_NAME(referenced_object_id) = ?", schema, schema).
+		Scan(&foreignKeys).Error
+	if err != nil {
+		return err
+	}
+	for _, fk := range foreignKeys {
+		err = tx.Exec("ALTER TABLE " + fk.TableName + " DROP CONSTRAINT " + fk.ConstraintName).Error
+		if err != nil {
+			return err
+		}
+	}
+	var objects []mssqlSchemaObject
+	err = tx.Raw("SELECT CASE RTRIM(type) WHEN 'V' THEN 'VIEW' WHEN 'P' THEN 'PROCEDURE' WHEN 'U' THEN 'TABLE' "+
+		"WHEN 'SO' THEN 'SEQUENCE' ELSE 'FUNCTION' END AS object_type, "+
+		"QUOTENAME(SCHEMA_NAME(schema_id)) + '.' + QUOTENAME(name) AS name FROM sys.objects "+
+		"WHERE schema_id = SCHEMA_ID(?) AND RTRIM(type) IN ('V', 'P', 'FN', 'IF', 'TF', 'U', 'SO') "+
+		"ORDER BY CASE RTRIM(type) WHEN 'V' THEN 0 WHEN 'P' THEN 1 WHEN 'U' THEN 3 WHEN 'SO' THEN 4 ELSE 2 END",
+		schema).Scan(&objects).Error
+	if err != nil {
+		return err
+	}
+	for _, object := range objects {
+		err = tx.Exec("DROP " + object.ObjectType + " " + object.Name).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getSchemaExistsQuery
+/* Function that returns catalog query that counts schemas with name passed as a parameter
+ * Parameters:
+ *     - dialect - string that represent using db driver inside gorm (see enum above)
+ * Returns query text or empty string for unsupported dialect
+ */
+func getSchemaExistsQuery(dialect SqlDialect) string {
+	switch dialect {
+	case Postgres:
+		return "SELECT COUNT(*) FROM pg_namespace WHERE nspname = ?"
+	case Mssql:
+		return "SELECT COUNT(*) FROM sys.schemas WHERE name = ?"
+	case Mysql:
+		return "SELECT COUNT(*) FROM information_schema.SCHEMATA WHERE SCHEMA_NAME = ?"
+	default:
+		return ""
+	}
+}
+
+// getDbNameOfDb
+/* Function that returns name of database that is opened by db, it is taken from connection string of dialector
+ * (without query to server, therefore it could be used when connection is broken)
+ * Returns database name or empty string if it is unknown
+ */
+func getDbNameOfDb(db *g.DB) string {
+	if db == nil {
+		return ""
+	}
+	connStr := ""
+	switch dialector := db.Dialector.(type) {
+	case *postgres.Dialector:
+		connStr = dialector.DSN
+	case *mysql.Dialector:
+		connStr = dialector.DSN
+	case *sqlserver.Dialector:
+		connStr = dialector.DSN
+	case *sqlite.Dialector:
+		connStr = dialector.DSN
+	default:
+		return ""
+	}
+	_, dbName := createSystemDbConnStr(getDialectOfDb(db), &connStr)
+	return dbName
+}
+
+// newSchemaError
+/* Function that creates DbError of schema operation
+ */
+func newSchemaError(ctx context.Context, kind error, dialect SqlDialect, dbName string, schema string,
+	err error) error {
+	dbErr := newDbErrorContext(ctx, kind, dialect, dbName, err).(*DbError)
+	dbErr.Schema = schema
+	return dbErr
+}
@@ -0,0 +1,87 @@
+package gorm
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"testing"
+)
+
+func TestApplySessionSchema(t *testing.T) {
+	session := SessionOptions{Schema: "Tenant-1", SearchPath: "public"}
+	connStr, _, err := applySessionOptions(Postgres, "host=localhost port=5432 user=root dbname=app sslmode=disable",
+		&session)
+	assert.NoError(t, err)
+	cfg, err := ParseConnectionString(Postgres, connStr)
+	assert.NoError(t, err)
+	assert.Equal(t, "\"Tenant-1\",public", cfg.Params["search_path"])
+	assert.Equal(t, "app", cfg.DbName)
+
+	connStr, _, err = applySessionOptions(Mysql, "root:123@tcp(localhost:3306)/app?charset=utf8mb4&parseTime=True&loc=Local",
+		&SessionOptions{Schema: "tenant_1"})
+	assert.NoError(t, err)
+	assert.Equal(t, "root:123@tcp(localhost:3306)/tenant_1?charset=utf8mb4&parseTime=True&loc=Local", connStr)
+
+	_, _, err = applySessionOptions(Postgres, "host=localhost dbname=app", &SessionOptions{Schema: "bad\x00schema"})
+	assert.True(t, errors.Is(err, ErrInvalidIdentifier))
+}
+
+func TestWithSessionSchema(t *testing.T) {
+	openOptions := OpenOptions{Pool: &PoolOptions{MaxOpenConns: 5}, Session: &SessionOptions{TimeZone: "UTC"}}
+	schemaOptions := withSessionSchema(&openOptions, "tenant_1")
+	assert.Equal(t, "tenant_1", schemaOptions.Session.Schema)
+	assert.Equal(t, "UTC", schemaOptions.Session.TimeZone)
+	assert.Equal(t, 5, schemaOptions.Pool.MaxOpenConns)
+	// original options are not modified
+	assert.Empty(t, openOptions.Session.Schema)
+	assert.Equal(t, "tenant_1", withSessionSchema(nil, "tenant_1").Session.Schema)
+}
+
+func TestSqliteSchemaIsNotSupported(t *testing.T) {
+	cfg := gorm.Config{}
+	db := OpenDb2(Sqlite, sqliteMemoryDb, false, false, &cfg, nil)
+	defer CloseDb(db)
+	err := CreateSchema(Sqlite, db, "app")
+	assert.True(t, errors.Is(err, ErrSchemaCreateFailed))
+	assert.True(t, errors.Is(err, ErrUnsupportedDialect))
+	assert.Contains(t, err.Error(), "schema: \"app\"")
+	_, err = SchemaExists(Sqlite, db, "app")
+	assert.True(t, errors.Is(err, ErrUnsupportedDialect))
+	err = DropSchema(Sqlite, db, "app", true)
+	assert.True(t, errors.Is(err, ErrSchemaDropFailed))
+
+	schemaDb, schema, err := CreateRandomSchemaContext(context.Background(), Sqlite, sqliteMemoryDb, &cfg, nil)
+	assert.Nil(t, schemaDb)
+	assert.Empty(t, schema)
+	assert.True(t, errors.Is(err, ErrUnsupportedDialect))
+}
+
+func TestPostgresSchemaLifecycle(t *testing.T) {
+	db, _ := CreateTestDb(t, Postgres, &gorm.Config{}, nil)
+	schema := "Tenant-1"
+	assert.NoError(t, CreateSchema(Postgres, db, schema))
+	exists, err := SchemaExists(Postgres, db, schema)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.True(t, errors.Is(CreateSchema(Postgres, db, schema), ErrSchemaExists))
+
+	assert.NoError(t, db.Exec("CREATE TABLE \"Tenant-1\".roles (id serial PRIMARY KEY)").Error)
+	err = DropSchema(Postgres, db, schema, false)
+	assert.True(t, errors.Is(err, ErrSchemaNotEmpty))
+	assert.NoError(t, DropSchema(Postgres, db, schema, true))
+	exists, err = SchemaExists(Postgres, db, schema)
+	assert.NoError(t, err)
+	assert.False(t, exists)
+	// missing schema
+	assert.NoError(t, DropSchema(Postgres, db, schema, true))
+}
+
+func TestPostgresCreateTestSchema(t *testing.T) {
+	db, schema := CreateTestSchema(t, Postgres, nil)
+	assert.NoError(t, db.AutoMigrate(&Role{}))
+	var tablesNumber int64
+	assert.NoError(t, db.Raw("SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = ? AND table_name = ?",
+		schema, "roles").Scan(&tablesNumber).Error)
+	assert.Equal(t, int64(1), tablesNumber)
+}
@@ -0,0 +1,320 @@
+package gorm
+
+import (
+	"context"
+	"errors"
+	"github.com/gofrs/uuid"
+	"github.com/wissance/stringFormatter"
+	g "gorm.io/gorm"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const tmpTemplateDatabaseNameTemplate = "wissance_tpl_db_{0}"
+
+// TemplateDb is a prepared (migrated and optionally seeded) database that is using as a source of cheap clones
+/* Clones are created in a dialect specific way:
+ *    - Postgres - CREATE DATABASE clone TEMPLATE template
+ *    - Mysql - tables are re-created from SHOW CREATE TABLE and data is copied with INSERT ... SELECT (views, routines
+ *      and triggers are not copied)
+ *    - Mssql - template is backed up (COPY_ONLY) once when it is created, clones are restored from this backup
+ *    - Sqlite - template file is copied
+ */
+type TemplateDb struct {
+	Dialect   SqlDialect
+	ConnStr   string
+	DbName    string
+	Collation *Collation
+	// mssqlBackupFile is a server side path to template backup
+	mssqlBackupFile string
+	// mssqlFiles are data and log files of template database
+	mssqlFiles []mssqlDbFile
+}
+
+// mssqlDbFile is a file of Mssql database (sys.master_files row)
+type mssqlDbFile struct {
+	FileId       int
+	Name         string
+	PhysicalName string
+}
+
+// CreateTemplateDb
+/* Function that creates template database with random name, calls prepare function (i.e. AutoMigrate and reference
+ * data seed) and closes all template connections (Postgres doesn't allow to use database as a template if it has
+ * connections). This function should be called once per test run (i.e. in TestMain), then every test creates its own
+ * clone with CreateRandomDbFromTemplate (or CreateTestDbFromTemplate)
+ * Parameters:
+ *    - ctx - context that bounds template creation
+ *    - dialect - string that represent using db driver inside gorm (see enum above)
+ *    - host - ip address / hostname of machine where database server is located
+ *    - port - integer value representing server tcp port (typically 5432 for postgres, 3306 for mysql and 1433 for mssql)
+ *    - dbUser - user that is using for perform operations on dbName
+ *    - password - dbUser password
+ *    - useSsl - sslmode (Postgres), tls (Mysql) or encrypt (Mssql) parameter value
+ *    - options - gorm config (from gorm.io/gorm NOT from github.com/jinzhu/gorm)
+ *    - collation a set of charset / collation options for template creation (clones have the same collation)
+ *    - prepare - function that migrates and seeds template (could be nil)
+ * Returns tuple of template and error, on error template database is dropped
+ */
+func CreateTemplateDb(ctx context.Context, dialect SqlDialect, host string, port int, dbUser string, password string,
+	useSsl string, options *g.Config, collation *Collation, prepare func(db *g.DB) error) (*TemplateDb, error) {
+	dbName := createRandomDbName(dialect, tmpTemplateDatabaseNameTemplate)
+	connStr := BuildConnectionString(dialect, host, port, dbName, dbUser, password, useSsl)
+	db, err := OpenDb2Context(ctx, dialect, connStr, true, false, options, collation, nil)
+	if err != nil {
+		return nil, err
+	}
+	template := TemplateDb{Dialect: dialect, ConnStr: connStr, DbName: dbName, Collation: collation}
+	if prepare != nil {
+		err = prepare(db.WithContext(ctx))
+	}
+	if err == nil && dialect == Mssql {
+		err = backupMssqlTemplateDb(ctx, db, &template)
+	}
+	CloseDb(db)
+	if err != nil {
+		_ = DropDbContext(ctx, dialect, connStr, options)
+		return nil, err
+	}
+	return &template, nil
+}
+
+// CreateRandomDbFromTemplate
+/* Function that creates database with random name as a clone of template and opens it, it could be dropped with
+ * DropDb like database that was created by CreateRandomDb
+ * Parameters:
+ *    - ctx - context that bounds clone creation and opening
+ *    - template - template that was created by CreateTemplateDb
+ *    - options - gorm config (from gorm.io/gorm NOT from github.com/jinzhu/gorm)
+ *    - openOptions - connection pool and session options (could be nil)
+ * Returns tuple of gorm.DB address of database context object, connStr and error
+ */
+func CreateRandomDbFromTemplate(ctx context.Context, template *TemplateDb, options *g.Config,
+	openOptions *OpenOptions) (*g.DB, string, error) {
+	dialect := template.Dialect
+	cloneName := createRandomDbName(dialect, tmpDatabaseNameTemplate)
+	cfg, err := ParseConnectionString(dialect, template.ConnStr)
+	if err != nil {
+		return nil, "", newDbError(ErrCreateFailed, dialect, cloneName, err)
+	}
+	cloneConnStr, err := cfg.WithDbName(cloneName).ConnectionString()
+	if err != nil {
+		return nil, "", newDbError(ErrCreateFailed, dialect, cloneName, err)
+	}
+	systemDbConnStr, _ := createSystemDbConnStr(dialect, &template.ConnStr)
+
+	switch dialect {
+	case Postgres:
+		err = execOnSystemDb(ctx, dialect, systemDbConnStr, options, "CREATE DATABASE {0} TEMPLATE {1}", cloneName,
+			template.DbName)
+	case Mysql:
+		err = cloneMysqlTemplateDb(ctx, template, systemDbConnStr, cloneConnStr, cloneName, options)
+	case Mssql:
+		err = restoreMssqlTemplateDb(ctx, template, systemDbConnStr, cloneName, options)
+	case Sqlite:
+		err = copyFile(template.DbName, cloneName)
+	default:
+		err = ErrUnsupportedDialect
+	}
+	if err != nil {
+		var dbErr *DbError
+		if !errors.As(err, &dbErr) {
+			err = newDbErrorContext(ctx, ErrCreateFailed, dialect, cloneName, err)
+		}
+		if dialect == Mysql || dialect == Sqlite {
+			// partially copied clone
+			_ = DropDb2Context(ctx, dialect, systemDbConnStr, cloneName, options)
+		}
+		return nil, "", err
+	}
+	db, err := openDb(ctx, dialect, cloneConnStr, options, openOptions)
+	return db, cloneConnStr, err
+}
+
+// DropTemplateDb
+/* Function that drops template database, Mssql template backup file is not removed from server
+ * Parameters:
+ *    - ctx - context that bounds dropping
+ *    - template - template that was created by CreateTemplateDb
+ *    - options - gorm config (from gorm.io/gorm NOT from github.com/jinzhu/gorm)
+ * Returns nil if template was dropped
+ */
+func DropTemplateDb(ctx context.Context, template *TemplateDb, options *g.Config) error {
+	return DropDbContext(ctx, template.Dialect, template.ConnStr, options)
+}
+
+// createRandomDbName
+/* Function that creates random database name from template ({0} is replaced with uuid without dashes), for Sqlite it
+ * is a path to file in temporary directory
+ */
+func createRandomDbName(dialect SqlDialect, nameTemplate string) string {
+	random, _ := uuid.NewV4()
+	dbName := stringFormatter.Format(nameTemplate, strings.Replace(random.String(), "-", "", -1))
+	if dialect == Sqlite {
+		dbName = filepath.Join(os.TempDir(), dbName+sqliteDbFileExtension)
+	}
+	return dbName
+}
+
+// execOnSystemDb
+/* Function that executes statement on system database, {0}, {1} ... placeholders of statement are replaced with
+ * quoted identifiers
+ */
+func execOnSystemDb(ctx context.Context, dialect SqlDialect, systemDbConnStr string, options *g.Config,
+	statementTemplate string, identifiers ...string) error {
+	quotedIdentifiers := make([]interface{}, len(identifiers))
+	for i, identifier := range identifiers {
+		quoted, err := QuoteIdentifier(dialect, identifier)
+		if err != nil {
+			return err
+		}
+		quotedIdentifiers[i] = quoted
+	}
+	systemDb, err := openDb(ctx, dialect, systemDbConnStr, options, nil)
+	if err != nil {
+		return err
+	}
+	defer CloseDb(systemDb)
+	return systemDb.WithContext(ctx).Exec(stringFormatter.Format(statementTemplate, quotedIdentifiers...)).Error
+}
+
+// cloneMysqlTemplateDb
+/* Function that creates Mysql database and copies tables (structure and data) from template to it, foreign keys
+ * checks are disabled during copy
+ */
+func cloneMysqlTemplateDb(ctx context.Context, template *TemplateDb, systemDbConnStr string, cloneConnStr string,
+	cloneName string, options *g.Config) error {
+	quotedTemplateName, err := QuoteIdentifier(Mysql, template.DbName)
+	if err != nil {
+		return err
+	}
+	quotedCloneName, err := QuoteIdentifier(Mysql, cloneName)
+	if err != nil {
+		return err
+	}
+	err = execOnSystemDb(ctx, Mysql, systemDbConnStr, options,
+		"CREATE DATABASE {0} "+createCollationOption(Mysql, template.Collation), cloneName)
+	if err != nil {
+		return err
+	}
+	cloneDb, err := openDb(ctx, Mysql, cloneConnStr, options,
+		&OpenOptions{Session: &SessionOptions{Variables: map[string]string{"foreign_key_checks": "0"}}})
+	if err != nil {
+		return err
+	}
+	defer CloseDb(cloneDb)
+	cloneDb = cloneDb.WithContext(ctx)
+	var tables []string
+	err = cloneDb.Raw("SELECT TABLE_NAME FROM information_schema.TABLES WHERE TABLE_SCHEMA = ? AND TABLE_TYPE = 'BASE TABLE'",
+		template.DbName).Scan(&tables).Error
+	if err != nil {
+		return err
+	}
+	for _, table := range tables {
+		quotedTable, quoteErr := QuoteIdentifier(Mysql, table)
+		if quoteErr != nil {
+			return quoteErr
+		}
+		var createStatement struct {
+			Table       string `gorm:"column:Table"`
+			CreateTable string `gorm:"column:Create Table"`
+		}
+		err = cloneDb.Raw("SHOW CREATE TABLE " + quotedTemplateName + "." + quotedTable).Scan(&createStatement).Error
+		if err != nil {
+			return err
+		}
+		err = cloneDb.Exec(createStatement.CreateTable).Error
+		if err != nil {
+			return err
+		}
+		err = cloneDb.Exec("INSERT INTO " + quotedCloneName + "." + quotedTable + " SELECT * FROM " +
+			quotedTemplateName + "." + quotedTable).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backupMssqlTemplateDb
+/* Function that saves Mssql template files list and makes COPY_ONLY backup of template next to its data file
+ */
+func backupMssqlTemplateDb(ctx context.Context, db *g.DB, template *TemplateDb) error {
+	db = db.WithContext(ctx)
+	err := db.Raw("SELECT file_id AS file_id, name AS name, physical_name AS physical_name FROM sys.master_files "+
+		"WHERE database_id = DB_ID(?) ORDER BY file_id", template.DbName).Scan(&template.mssqlFiles).Error
+	if err != nil {
+		return err
+	}
+	if len(template.mssqlFiles) == 0 {
+		return errors.New("template database files were not found")
+	}
+	quotedName, err := QuoteIdentifier(Mssql, template.DbName)
+	if err != nil {
+		return err
+	}
+	template.mssqlBackupFile = getServerDirectory(template.mssqlFiles[0].PhysicalName) + template.DbName + ".bak"
+	return db.Exec("BACKUP DATABASE "+quotedName+" TO DISK = ? WITH COPY_ONLY, INIT", template.mssqlBackupFile).Error
+}
+
+// restoreMssqlTemplateDb
+/* Function that restores Mssql template backup as a new database, every template file is moved to a file with clone
+ * name in the same directory
+ */
+func restoreMssqlTemplateDb(ctx context.Context, template *TemplateDb, systemDbConnStr string, cloneName string,
+	options *g.Config) error {
+	if len(template.mssqlBackupFile) == 0 {
+		return errors.New("template database backup was not created")
+	}
+	quotedName, err := QuoteIdentifier(Mssql, cloneName)
+	if err != nil {
+		return err
+	}
+	statement := "RESTORE DATABASE " + quotedName + " FROM DISK = ? WITH RECOVERY"
+	args := []interface{}{template.mssqlBackupFile}
+	for _, file := range template.mssqlFiles {
+		extension := filepath.Ext(file.PhysicalName)
+		newFile := getServerDirectory(file.PhysicalName) + cloneName + "_" + stringFormatter.Format("{0}", file.FileId) +
+			extension
+		statement = statement + ", MOVE ? TO ?"
+		args = append(args, file.Name, newFile)
+	}
+	systemDb, err := openDb(ctx, Mssql, systemDbConnStr, options, nil)
+	if err != nil {
+		return err
+	}
+	defer CloseDb(systemDb)
+	return systemDb.WithContext(ctx).Exec(statement, args...).Error
+}
+
+// getServerDirectory
+/* Function that returns directory (with trailing separator) of server side file path, path could be both Windows or
+ * Linux one, therefore it couldn't be processed with filepath
+ */
+func getServerDirectory(path string) string {
+	separatorIndex := strings.LastIndexAny(path, "\\/")
+	return path[:separatorIndex+1]
+}
+
+// copyFile
+/* Function that copies file (Sqlite template database) into new file
+ */
+func copyFile(source string, destination string) error {
+	sourceFile, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer sourceFile.Close()
+	destinationFile, err := os.OpenFile(destination, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(destinationFile, sourceFile)
+	closeErr := destinationFile.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
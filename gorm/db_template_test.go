@@ -0,0 +1,80 @@
+package gorm
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"os"
+	"testing"
+)
+
+func TestCreateSqliteDbFromTemplate(t *testing.T) {
+	cfg := gorm.Config{}
+	ctx := context.Background()
+	template, err := CreateTemplateDb(ctx, Sqlite, "", 0, "", "", "", &cfg, nil, func(db *gorm.DB) error {
+		prepareDatabase(db)
+		return db.Create(&Role{Name: "template_role"}).Error
+	})
+	assert.NoError(t, err)
+	defer func() {
+		assert.NoError(t, DropTemplateDb(ctx, template, &cfg))
+		assert.False(t, CheckDb(Sqlite, template.ConnStr, &cfg))
+	}()
+
+	firstDb, firstConnStr, err := CreateRandomDbFromTemplate(ctx, template, &cfg, nil)
+	assert.NoError(t, err)
+	secondDb, secondConnStr, err := CreateRandomDbFromTemplate(ctx, template, &cfg, nil)
+	assert.NoError(t, err)
+	assert.NotEqual(t, firstConnStr, secondConnStr)
+
+	// clones contain template data and are independent of each other
+	assert.NoError(t, firstDb.Create(&Role{Name: "clone_role"}).Error)
+	var firstRoles []Role
+	assert.NoError(t, firstDb.Order("id").Find(&firstRoles).Error)
+	assert.Equal(t, 2, len(firstRoles))
+	assert.Equal(t, "template_role", firstRoles[0].Name)
+	var secondRoles []Role
+	assert.NoError(t, secondDb.Find(&secondRoles).Error)
+	assert.Equal(t, 1, len(secondRoles))
+
+	CloseDb(firstDb)
+	CloseDb(secondDb)
+	DropDb(Sqlite, firstConnStr, &cfg)
+	DropDb(Sqlite, secondConnStr, &cfg)
+	assert.False(t, CheckDb(Sqlite, firstConnStr, &cfg))
+	assert.False(t, CheckDb(Sqlite, secondConnStr, &cfg))
+}
+
+func TestCreateTemplateDbDropsTemplateOnPrepareError(t *testing.T) {
+	cfg := gorm.Config{}
+	// template file is created in os.TempDir()
+	tmpDir := t.TempDir()
+	t.Setenv("TMPDIR", tmpDir)
+	template, err := CreateTemplateDb(context.Background(), Sqlite, "", 0, "", "", "", &cfg, nil, func(db *gorm.DB) error {
+		return db.Exec("SELECT * FROM missing_table").Error
+	})
+	assert.Nil(t, template)
+	assert.Error(t, err)
+	files, err := os.ReadDir(tmpDir)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(files))
+}
+
+func TestCreateSqliteTestDbFromTemplate(t *testing.T) {
+	cfg := gorm.Config{}
+	template, err := CreateTemplateDb(context.Background(), Sqlite, "", 0, "", "", "", &cfg, nil, func(db *gorm.DB) error {
+		prepareDatabase(db)
+		return nil
+	})
+	assert.NoError(t, err)
+	defer DropTemplateDb(context.Background(), template, &cfg)
+
+	var connStr string
+	t.Run("use test database", func(t *testing.T) {
+		var db *gorm.DB
+		db, connStr = CreateTestDbFromTemplate(t, template, &cfg)
+		assert.NoError(t, db.Create(&Role{Name: "fixture"}).Error)
+	})
+	_, statErr := os.Stat(connStr)
+	assert.True(t, os.IsNotExist(statErr))
+}
@@ -0,0 +1,471 @@
+package gorm
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	g "gorm.io/gorm"
+	"sync"
+	"time"
+)
+
+const defaultMaxOpenTenants = 100
+const defaultTenantIdleTimeout = 10 * time.Minute
+
+var (
+	// ErrTenantManagerClosed is returned by TenantManager methods after Close
+	ErrTenantManagerClosed = errors.New("tenant manager is closed")
+	// ErrTenantNotResolved is returned when resolver returned neither config nor error
+	ErrTenantNotResolved = errors.New("tenant config is not resolved")
+	// ErrTenantOpenAborted is returned to callers that waited for opening of tenant database that was aborted by panic
+	// (i.e. in resolver or migration function)
+	ErrTenantOpenAborted = errors.New("tenant database opening was aborted")
+)
+
+// TenantConfig is a connection settings of tenant database
+type TenantConfig struct {
+	Dialect SqlDialect
+	// ConnStr is a full connection string of tenant database
+	ConnStr string
+	// Collation is a collation of created database (could be nil)
+	Collation *Collation
+	// Open are connection pool limits, session settings and retry policy (could be nil)
+	Open *OpenOptions
+}
+
+// TenantResolver resolves tenant key to connection settings of tenant database
+type TenantResolver func(ctx context.Context, tenant string) (*TenantConfig, error)
+
+// TenantMigrator migrates tenant database after it was opened (i.e. with AutoMigrate or Migrator)
+type TenantMigrator func(ctx context.Context, tenant string, db *g.DB) error
+
+// TenantManagerOptions is an options of TenantManager
+type TenantManagerOptions struct {
+	// Config is a gorm config of tenant databases
+	Config *g.Config
+	// Migrate is called once for every opened tenant database before it is cached (could be nil)
+	Migrate TenantMigrator
+	// MaxOpen is a maximum number of cached tenant databases, least recently used database is closed when it is
+	// exceeded, 100 if not set
+	MaxOpen int
+	// IdleTimeout is a duration after which unused tenant database is closed, 10 minutes if not set, negative value
+	// disables idle close
+	IdleTimeout time.Duration
+}
+
+// tenantDb is a cached tenant database, refs is a number of not released handles (see Get), database that was removed
+// from cache while it is in use is closed by last release
+type tenantDb struct {
+	tenant   string
+	db       *g.DB
+	lastUsed time.Time
+	refs     int
+	removed  bool
+	closed   bool
+}
+
+// tenantOperation is an opening or dropping of tenant database that is in progress, concurrent callers wait for done
+type tenantOperation struct {
+	done chan struct{}
+	drop bool
+	err  error
+}
+
+// TenantManager gives database of tenant (database-per-tenant), database is created (OpenDb2 with create), migrated
+// and cached on first use. Concurrent first accesses to same tenant wait for single opening, therefore CREATE DATABASE
+// is not raced. Cached databases are removed from cache when they are idle or least recently used and cache is full,
+// database is closed when all its handles that were returned by Get are released, database that was removed is
+// reopened by next Get, therefore *gorm.DB should not be used after release
+type TenantManager struct {
+	resolver   TenantResolver
+	options    TenantManagerOptions
+	mutex      sync.Mutex
+	tenants    map[string]*list.Element
+	lru        *list.List
+	operations map[string]*tenantOperation
+	closed     bool
+	stop       chan struct{}
+	wg         sync.WaitGroup
+}
+
+// NewTenantManager
+/* Function that creates tenant manager and starts idle databases closing
+ * Parameters:
+ *    - resolver - function that resolves tenant key to connection settings
+ *    - options - gorm config, migration function, cache size and idle timeout (could be nil)
+ * Returns tenant manager address
+ */
+func NewTenantManager(resolver TenantResolver, options *TenantManagerOptions) *TenantManager {
+	manager := TenantManager{resolver: resolver, tenants: map[string]*list.Element{}, lru: list.New(),
+		operations: map[string]*tenantOperation{}, stop: make(chan struct{})}
+	if options != nil {
+		manager.options = *options
+	}
+	if manager.options.Config == nil {
+		manager.options.Config = &g.Config{}
+	}
+	if manager.options.MaxOpen < 1 {
+		manager.options.MaxOpen = defaultMaxOpenTenants
+	}
+	if manager.options.IdleTimeout == 0 {
+		manager.options.IdleTimeout = defaultTenantIdleTimeout
+	}
+	if manager.options.IdleTimeout > 0 {
+		manager.wg.Add(1)
+		go manager.closeIdlePeriodically()
+	}
+	return &manager
+}
+
+// Get
+/* Function that returns database of tenant, if it is not cached it is created (if it does not exist), opened and
+ * migrated, concurrent callers of same tenant wait for this. Database is not closed (i.e. by eviction or idle close)
+ * until returned release function is called, it should be called when request handling is done (i.e. with defer)
+ * Parameters:
+ *    - ctx - context that bounds resolving, creation and migration of database (and waiting for it)
+ *    - tenant - tenant key
+ * Returns tuple of gorm.DB address of database context object, release function (it could be called more than once)
+ * and error
+ */
+func (m *TenantManager) Get(ctx context.Context, tenant string) (*g.DB, func(), error) {
+	for {
+		m.mutex.Lock()
+		if m.closed {
+			m.mutex.Unlock()
+			return nil, nil, ErrTenantManagerClosed
+		}
+		if element, ok := m.tenants[tenant]; ok {
+			cached := element.Value.(*tenantDb)
+			cached.lastUsed = time.Now()
+			cached.refs++
+			m.lru.MoveToFront(element)
+			m.mutex.Unlock()
+			return cached.db, m.releaseFunc(cached), nil
+		}
+		if operation, ok := m.operations[tenant]; ok {
+			m.mutex.Unlock()
+			err := waitTenantOperation(ctx, operation)
+			if err != nil {
+				return nil, nil, err
+			}
+			if operation.err != nil && !operation.drop && !isContextError(operation.err) {
+				return nil, nil, operation.err
+			}
+			// database is opened (or dropped, or opening was cancelled by ctx of other caller), it is taken from
+			// cache (or opened with own ctx) on next iteration
+			continue
+		}
+		operation := &tenantOperation{done: make(chan struct{})}
+		m.operations[tenant] = operation
+		m.mutex.Unlock()
+
+		cached, err := m.openAndCache(ctx, tenant, operation)
+		if err != nil {
+			return nil, nil, err
+		}
+		return cached.db, m.releaseFunc(cached), nil
+	}
+}
+
+// Release
+/* Function that removes database of tenant from cache (if it is cached) and closes it (if it is not in use, otherwise it
+ * is closed when it is released), next Get opens it again
+ * Parameters:
+ *    - tenant - tenant key
+ * Returns error of close (DbError with ErrCloseFailed kind)
+ */
+func (m *TenantManager) Release(tenant string) error {
+	m.mutex.Lock()
+	element, ok := m.tenants[tenant]
+	if !ok {
+		m.mutex.Unlock()
+		return nil
+	}
+	db := m.removeLocked(element)
+	m.mutex.Unlock()
+	if db == nil {
+		return nil
+	}
+	return CloseDbWithError(db)
+}
+
+// DropTenant
+/* Function that closes cached database of tenant (even if it is in use) and drops it, Get calls of same tenant wait for
+ * dropping and create new database after it
+ * Parameters:
+ *    - ctx - context that bounds resolving and dropping of database
+ *    - tenant - tenant key
+ * Returns error of resolver or dropping (DbError with ErrDropFailed kind)
+ */
+func (m *TenantManager) DropTenant(ctx context.Context, tenant string) error {
+	var operation *tenantOperation
+	var db *g.DB
+	for operation == nil {
+		m.mutex.Lock()
+		if m.closed {
+			m.mutex.Unlock()
+			return ErrTenantManagerClosed
+		}
+		if pending, ok := m.operations[tenant]; ok {
+			m.mutex.Unlock()
+			err := waitTenantOperation(ctx, pending)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		operation = &tenantOperation{done: make(chan struct{}), drop: true}
+		m.operations[tenant] = operation
+		if element, ok := m.tenants[tenant]; ok {
+			db = m.closeLocked(element)
+		}
+		m.mutex.Unlock()
+	}
+	defer func() {
+		m.mutex.Lock()
+		delete(m.operations, tenant)
+		m.mutex.Unlock()
+		close(operation.done)
+	}()
+	if db != nil {
+		// database could not be dropped while it has open connections
+		_ = CloseDbWithError(db)
+	}
+	config, err := m.resolve(ctx, tenant)
+	if err != nil {
+		operation.err = err
+		return err
+	}
+	operation.err = DropDbContext(ctx, config.Dialect, config.ConnStr, m.options.Config)
+	return operation.err
+}
+
+// Close
+/* Function that stops idle databases closing and closes all cached databases (even if they are in use), manager could
+ * not be used after it
+ * Returns error of first failed close (DbError with ErrCloseFailed kind)
+ */
+func (m *TenantManager) Close() error {
+	m.mutex.Lock()
+	if m.closed {
+		m.mutex.Unlock()
+		return nil
+	}
+	m.closed = true
+	dbs := make([]*g.DB, 0, m.lru.Len())
+	for m.lru.Len() > 0 {
+		dbs = append(dbs, m.closeLocked(m.lru.Back()))
+	}
+	m.mutex.Unlock()
+	close(m.stop)
+	m.wg.Wait()
+	var closeErr error
+	for _, db := range dbs {
+		err := CloseDbWithError(db)
+		if err != nil && closeErr == nil {
+			closeErr = err
+		}
+	}
+	return closeErr
+}
+
+// openAndCache
+/* Function that opens tenant database and puts it to cache, operation is completed and removed in defer, therefore
+ * callers that wait for it are not blocked forever if opening panics (they get ErrTenantOpenAborted)
+ * Returns cached database with one handle or error
+ */
+func (m *TenantManager) openAndCache(ctx context.Context, tenant string, operation *tenantOperation) (*tenantDb, error) {
+	var evicted []*g.DB
+	operation.err = ErrTenantOpenAborted
+	defer func() {
+		m.mutex.Lock()
+		delete(m.operations, tenant)
+		m.mutex.Unlock()
+		close(operation.done)
+		closeTenantDbs(evicted)
+	}()
+	db, err := m.open(ctx, tenant)
+	var cached *tenantDb
+	m.mutex.Lock()
+	if err == nil {
+		if m.closed {
+			err = ErrTenantManagerClosed
+			evicted = append(evicted, db)
+		} else {
+			cached = &tenantDb{tenant: tenant, db: db, lastUsed: time.Now(), refs: 1}
+			m.tenants[tenant] = m.lru.PushFront(cached)
+			for m.lru.Len() > m.options.MaxOpen {
+				evicted = appendNotNil(evicted, m.removeLocked(m.lru.Back()))
+			}
+		}
+	}
+	operation.err = err
+	m.mutex.Unlock()
+	return cached, err
+}
+
+// open
+/* Function that resolves tenant settings, creates (if it does not exist), opens and migrates tenant database
+ */
+func (m *TenantManager) open(ctx context.Context, tenant string) (*g.DB, error) {
+	config, err := m.resolve(ctx, tenant)
+	if err != nil {
+		return nil, err
+	}
+	db, err := OpenDb2Context(ctx, config.Dialect, config.ConnStr, true, true, m.options.Config, config.Collation,
+		config.Open)
+	if err != nil {
+		return nil, err
+	}
+	if m.options.Migrate != nil {
+		err = m.options.Migrate(ctx, tenant, db)
+		if err != nil {
+			_ = CloseDbWithError(db)
+			return nil, err
+		}
+	}
+	return db, nil
+}
+
+// resolve
+/* Function that resolves tenant settings with resolver and checks that they are returned
+ */
+func (m *TenantManager) resolve(ctx context.Context, tenant string) (*TenantConfig, error) {
+	config, err := m.resolver(ctx, tenant)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return nil, newWrappedError(ErrTenantNotResolved, nil, "resolver returned no config of tenant \"{0}\"", tenant)
+	}
+	return config, nil
+}
+
+// removeLocked
+/* Function that removes database from cache, mutex should be held by caller
+ * Returns removed database that should be closed by caller or nil if it is in use (it is closed by last release)
+ */
+func (m *TenantManager) removeLocked(element *list.Element) *g.DB {
+	cached := m.lru.Remove(element).(*tenantDb)
+	delete(m.tenants, cached.tenant)
+	cached.removed = true
+	if cached.refs > 0 {
+		return nil
+	}
+	cached.closed = true
+	return cached.db
+}
+
+// closeLocked
+/* Function that removes database from cache regardless of its handles, mutex should be held by caller
+ * Returns removed database that should be closed by caller
+ */
+func (m *TenantManager) closeLocked(element *list.Element) *g.DB {
+	cached := element.Value.(*tenantDb)
+	m.removeLocked(element)
+	cached.closed = true
+	return cached.db
+}
+
+// releaseFunc
+/* Function that creates release function of database handle, it decrements handles number, moves cached database to
+ * front of LRU list (it was used until now, therefore closeIdle that stops at first not idle database sees it in right
+ * order) and closes database that was removed from cache if it was a last handle
+ */
+func (m *TenantManager) releaseFunc(cached *tenantDb) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			m.mutex.Lock()
+			cached.refs--
+			cached.lastUsed = time.Now()
+			if element, ok := m.tenants[cached.tenant]; ok && !cached.removed {
+				m.lru.MoveToFront(element)
+			}
+			closeNow := cached.refs == 0 && cached.removed && !cached.closed
+			if closeNow {
+				cached.closed = true
+			}
+			m.mutex.Unlock()
+			if closeNow {
+				_ = CloseDbWithError(cached.db)
+			}
+		})
+	}
+}
+
+// closeIdle
+/* Function that closes databases that were not used during IdleTimeout and have no not released handles
+ * Returns number of closed databases
+ */
+func (m *TenantManager) closeIdle() int {
+	threshold := time.Now().Add(-m.options.IdleTimeout)
+	var idle []*g.DB
+	m.mutex.Lock()
+	// least recently used databases are at the back of the list
+	for element := m.lru.Back(); element != nil && element.Value.(*tenantDb).lastUsed.Before(threshold); {
+		previous := element.Prev()
+		if element.Value.(*tenantDb).refs == 0 {
+			idle = append(idle, m.removeLocked(element))
+		}
+		element = previous
+	}
+	m.mutex.Unlock()
+	closeTenantDbs(idle)
+	return len(idle)
+}
+
+// closeIdlePeriodically
+/* Function that closes idle databases every half of IdleTimeout until manager is closed
+ */
+func (m *TenantManager) closeIdlePeriodically() {
+	defer m.wg.Done()
+	ticker := time.NewTicker(m.options.IdleTimeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			m.closeIdle()
+		}
+	}
+}
+
+// waitTenantOperation
+/* Function that waits until tenant operation is done or ctx is cancelled
+ */
+func waitTenantOperation(ctx context.Context, operation *tenantOperation) error {
+	select {
+	case <-operation.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// isContextError
+/* Function that checks whether error is a cancellation or deadline of context
+ */
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// appendNotNil
+/* Function that appends database to slice if it is not nil
+ */
+func appendNotNil(dbs []*g.DB, db *g.DB) []*g.DB {
+	if db == nil {
+		return dbs
+	}
+	return append(dbs, db)
+}
+
+// closeTenantDbs
+/* Function that closes evicted or idle databases, close errors are ignored because databases are not used anymore
+ */
+func closeTenantDbs(dbs []*g.DB) {
+	for _, db := range dbs {
+		_ = CloseDbWithError(db)
+	}
+}
@@ -0,0 +1,266 @@
+package gorm
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSqliteTenantManagerGetWithConcurrentFirstAccess(t *testing.T) {
+	manager, resolved, migrated := createTestTenantManager(t, &TenantManagerOptions{IdleTimeout: -1})
+	ctx := context.Background()
+	dbs := make([]*gorm.DB, 10)
+	var wg sync.WaitGroup
+	for i := range dbs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			db, release, err := manager.Get(ctx, "acme")
+			assert.NoError(t, err)
+			defer release()
+			dbs[i] = db
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), resolved.Load())
+	assert.Equal(t, int32(1), migrated.Load())
+	for _, db := range dbs {
+		assert.Same(t, dbs[0], db)
+	}
+	assert.NoError(t, dbs[0].Create(&Role{Name: "admin"}).Error)
+	assertRowsNumber(t, dbs[0], "roles", 1)
+}
+
+func TestSqliteTenantManagerEviction(t *testing.T) {
+	manager, resolved, migrated := createTestTenantManager(t, &TenantManagerOptions{MaxOpen: 2,
+		IdleTimeout: time.Hour})
+	ctx := context.Background()
+	for _, tenant := range []string{"acme", "globex", "acme", "initech"} {
+		_, release, err := manager.Get(ctx, tenant)
+		assert.NoError(t, err)
+		release()
+	}
+	// globex is a least recently used tenant
+	assert.Equal(t, 2, manager.lru.Len())
+	assert.NotContains(t, manager.tenants, "globex")
+	_, release, err := manager.Get(ctx, "globex")
+	assert.NoError(t, err)
+	release()
+	// release could be called more than once
+	release()
+	assert.Equal(t, int32(4), resolved.Load())
+	assert.Equal(t, int32(4), migrated.Load())
+
+	// acme was evicted by globex, initech is a least recently used tenant
+	assert.NotContains(t, manager.tenants, "acme")
+	manager.tenants["initech"].Value.(*tenantDb).lastUsed = time.Now().Add(-2 * time.Hour)
+	assert.Equal(t, 1, manager.closeIdle())
+	assert.NotContains(t, manager.tenants, "initech")
+	assert.NoError(t, manager.Release("globex"))
+	assert.NoError(t, manager.Release("globex"))
+	assert.Equal(t, 0, manager.lru.Len())
+}
+
+func TestSqliteTenantManagerEvictionWithOpenTransaction(t *testing.T) {
+	manager, _, _ := createTestTenantManager(t, &TenantManagerOptions{MaxOpen: 1, IdleTimeout: time.Hour})
+	ctx := context.Background()
+	db, release, err := manager.Get(ctx, "acme")
+	assert.NoError(t, err)
+	tx := db.Begin()
+	assert.NoError(t, tx.Error)
+	assert.NoError(t, tx.Create(&Role{Name: "admin"}).Error)
+
+	// acme is evicted by globex but it is not closed until it is released
+	_, releaseGlobex, err := manager.Get(ctx, "globex")
+	assert.NoError(t, err)
+	defer releaseGlobex()
+	assert.NotContains(t, manager.tenants, "acme")
+	assert.NoError(t, tx.Create(&Role{Name: "guest"}).Error)
+	assert.NoError(t, tx.Commit().Error)
+	assertRowsNumber(t, db, "roles", 2)
+	release()
+	assert.Error(t, db.Exec("SELECT 1").Error)
+
+	// database that is in use is not closed as idle
+	manager.tenants["globex"].Value.(*tenantDb).lastUsed = time.Now().Add(-2 * time.Hour)
+	assert.Equal(t, 0, manager.closeIdle())
+	assert.Contains(t, manager.tenants, "globex")
+}
+
+func TestSqliteTenantManagerCloseIdleAfterLongRelease(t *testing.T) {
+	manager, _, _ := createTestTenantManager(t, &TenantManagerOptions{IdleTimeout: time.Hour})
+	ctx := context.Background()
+	_, releaseAcme, err := manager.Get(ctx, "acme")
+	assert.NoError(t, err)
+	_, releaseGlobex, err := manager.Get(ctx, "globex")
+	assert.NoError(t, err)
+	releaseGlobex()
+	// acme was used until now, therefore globex becomes a least recently used tenant
+	releaseAcme()
+	manager.tenants["globex"].Value.(*tenantDb).lastUsed = time.Now().Add(-2 * time.Hour)
+	assert.Equal(t, 1, manager.closeIdle())
+	assert.NotContains(t, manager.tenants, "globex")
+	assert.Contains(t, manager.tenants, "acme")
+}
+
+func TestSqliteTenantManagerWaiterRetriesWhenOpenerCancelled(t *testing.T) {
+	dir := t.TempDir()
+	entered := make(chan struct{}, 2)
+	proceed := make(chan struct{})
+	manager := NewTenantManager(func(ctx context.Context, tenant string) (*TenantConfig, error) {
+		entered <- struct{}{}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-proceed:
+			return &TenantConfig{Dialect: Sqlite, ConnStr: filepath.Join(dir, tenant+".db")}, nil
+		}
+	}, &TenantManagerOptions{IdleTimeout: -1})
+	defer manager.Close()
+
+	openerCtx, cancel := context.WithCancel(context.Background())
+	openerErr := make(chan error)
+	go func() {
+		_, _, err := manager.Get(openerCtx, "acme")
+		openerErr <- err
+	}()
+	<-entered
+	waiterErr := make(chan error)
+	go func() {
+		_, release, err := manager.Get(context.Background(), "acme")
+		if err == nil {
+			release()
+		}
+		waiterErr <- err
+	}()
+	// waiter waits for opening of acme
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	assert.True(t, errors.Is(<-openerErr, context.Canceled))
+	close(proceed)
+	assert.NoError(t, <-waiterErr)
+}
+
+func TestSqliteTenantManagerDropTenant(t *testing.T) {
+	manager, _, migrated := createTestTenantManager(t, nil)
+	ctx := context.Background()
+	db, release, err := manager.Get(ctx, "acme")
+	assert.NoError(t, err)
+	assert.NoError(t, db.Create(&Role{Name: "admin"}).Error)
+	release()
+	config, _ := manager.resolver(ctx, "acme")
+	assert.NoError(t, manager.DropTenant(ctx, "acme"))
+	_, err = os.Stat(config.ConnStr)
+	assert.True(t, os.IsNotExist(err))
+
+	// dropped database is created again
+	db, release, err = manager.Get(ctx, "acme")
+	assert.NoError(t, err)
+	assertRowsNumber(t, db, "roles", 0)
+	release()
+	assert.Equal(t, int32(2), migrated.Load())
+
+	assert.NoError(t, manager.Close())
+	_, _, err = manager.Get(ctx, "acme")
+	assert.True(t, errors.Is(err, ErrTenantManagerClosed))
+	assert.True(t, errors.Is(manager.DropTenant(ctx, "acme"), ErrTenantManagerClosed))
+}
+
+func TestTenantManagerGetWhenResolverFailed(t *testing.T) {
+	resolverErr := errors.New("unknown tenant")
+	manager := NewTenantManager(func(ctx context.Context, tenant string) (*TenantConfig, error) {
+		return nil, resolverErr
+	}, nil)
+	defer manager.Close()
+	_, _, err := manager.Get(context.Background(), "acme")
+	assert.Equal(t, resolverErr, err)
+	assert.Empty(t, manager.operations)
+}
+
+func TestTenantManagerGetWhenResolverReturnedNoConfig(t *testing.T) {
+	manager := NewTenantManager(func(ctx context.Context, tenant string) (*TenantConfig, error) {
+		return nil, nil
+	}, nil)
+	defer manager.Close()
+	_, _, err := manager.Get(context.Background(), "acme")
+	assert.True(t, errors.Is(err, ErrTenantNotResolved))
+	assert.True(t, errors.Is(manager.DropTenant(context.Background(), "acme"), ErrTenantNotResolved))
+	assert.Empty(t, manager.operations)
+}
+
+func TestSqliteTenantManagerWaiterIsReleasedWhenOpenerPanicked(t *testing.T) {
+	dir := t.TempDir()
+	entered := make(chan struct{}, 2)
+	proceed := make(chan struct{})
+	migrated := atomic.Int32{}
+	manager := NewTenantManager(func(ctx context.Context, tenant string) (*TenantConfig, error) {
+		entered <- struct{}{}
+		<-proceed
+		return &TenantConfig{Dialect: Sqlite, ConnStr: filepath.Join(dir, tenant+".db")}, nil
+	}, &TenantManagerOptions{IdleTimeout: -1, Migrate: func(ctx context.Context, tenant string, db *gorm.DB) error {
+		if migrated.Add(1) == 1 {
+			panic("migration failed")
+		}
+		return nil
+	}})
+	defer manager.Close()
+
+	openerPanic := make(chan interface{})
+	go func() {
+		defer func() {
+			openerPanic <- recover()
+		}()
+		_, _, _ = manager.Get(context.Background(), "acme")
+	}()
+	<-entered
+	waiterErr := make(chan error)
+	go func() {
+		_, _, err := manager.Get(context.Background(), "acme")
+		waiterErr <- err
+	}()
+	// waiter waits for opening of acme
+	time.Sleep(20 * time.Millisecond)
+	close(proceed)
+	assert.Equal(t, "migration failed", <-openerPanic)
+	assert.True(t, errors.Is(<-waiterErr, ErrTenantOpenAborted))
+	assert.Empty(t, manager.operations)
+
+	// next Get opens database again
+	_, release, err := manager.Get(context.Background(), "acme")
+	assert.NoError(t, err)
+	release()
+}
+
+// createTestTenantManager
+/* Function that creates tenant manager of Sqlite databases in temporary directory, tenant databases are migrated with
+ * prepareDatabase, numbers of resolver and migration calls are counted
+ */
+func createTestTenantManager(t *testing.T, options *TenantManagerOptions) (*TenantManager, *atomic.Int32,
+	*atomic.Int32) {
+	dir := t.TempDir()
+	resolved := &atomic.Int32{}
+	migrated := &atomic.Int32{}
+	if options == nil {
+		options = &TenantManagerOptions{}
+	}
+	options.Migrate = func(ctx context.Context, tenant string, db *gorm.DB) error {
+		migrated.Add(1)
+		prepareDatabase(db)
+		return nil
+	}
+	manager := NewTenantManager(func(ctx context.Context, tenant string) (*TenantConfig, error) {
+		resolved.Add(1)
+		return &TenantConfig{Dialect: Sqlite, ConnStr: filepath.Join(dir, tenant+".db")}, nil
+	}, options)
+	t.Cleanup(func() {
+		_ = manager.Close()
+	})
+	return manager, resolved, migrated
+}
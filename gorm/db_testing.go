@@ -0,0 +1,216 @@
+package gorm
+
+import (
+	"context"
+	"errors"
+	"github.com/wissance/stringFormatter"
+	g "gorm.io/gorm"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Environment variables templates that are using by CreateTestDb, {0} is an upper-cased dialect name (i.e.
+// GWUU_TEST_POSTGRES_HOST)
+const (
+	TestDbHostEnvTemplate     = "GWUU_TEST_{0}_HOST"
+	TestDbPortEnvTemplate     = "GWUU_TEST_{0}_PORT"
+	TestDbUserEnvTemplate     = "GWUU_TEST_{0}_USER"
+	TestDbPasswordEnvTemplate = "GWUU_TEST_{0}_PASSWORD"
+	TestDbSslModeEnvTemplate  = "GWUU_TEST_{0}_SSLMODE"
+	// TestDbNameEnvTemplate is an existing database where CreateTestSchema creates schemas (system database by default)
+	TestDbNameEnvTemplate = "GWUU_TEST_{0}_DBNAME"
+	// TestDbTimeoutEnv is a timeout of database creation (Go duration string, i.e. 10s)
+	TestDbTimeoutEnv = "GWUU_TEST_DB_TIMEOUT"
+)
+
+const defaultTestDbHost = "127.0.0.1"
+const defaultTestDbTimeout = 10 * time.Second
+
+// defaultTestDbPorts are ports that are using if port environment variable is not set
+var defaultTestDbPorts = map[SqlDialect]int{Postgres: 5432, Mysql: 3306, Mssql: 1433}
+
+// testDbServer is a database server settings that are read from environment variables
+type testDbServer struct {
+	dialect  SqlDialect
+	host     string
+	port     int
+	user     string
+	password string
+	sslMode  string
+}
+
+// CreateTestDb
+/* Function that creates throwaway database with random name (see CreateRandomDb) for a test, host, port, credentials
+ * and ssl mode are read from environment variables (see TestDb*EnvTemplate constants), if they are not set, 127.0.0.1,
+ * default dialect port and empty credentials are using. Sqlite database doesn't require any variables.
+ * When test (or subtest) finishes database is closed and dropped (via t.Cleanup). If database server is not reachable
+ * test is skipped, any other error (i.e. authentication failure) fails test
+ * Parameters:
+ *    - t - test state
+ *    - dialect - string that represent using db driver inside gorm (see enum above)
+ *    - options - gorm config, if nil empty config is using
+ *    - collation a set of charset / collation options for database creation
+ * Returns tuple of gorm.DB address of database context object and connStr
+ */
+func CreateTestDb(t testing.TB, dialect SqlDialect, options *g.Config, collation *Collation) (*g.DB, string) {
+	t.Helper()
+	if options == nil {
+		options = &g.Config{}
+	}
+	server := getTestDbServer(t, dialect)
+	ctx, cancel := context.WithTimeout(context.Background(), getTestDbTimeout(t))
+	defer cancel()
+	db, connStr, err := CreateRandomDbContext(ctx, dialect, server.host, server.port, server.user, server.password,
+		server.sslMode, options, collation, nil)
+	if err != nil {
+		server.skipIfNotReachable(t, err)
+		t.Fatalf("unable to create %s test database: %v", dialect, err)
+	}
+	t.Cleanup(func() {
+		CloseDb(db)
+		dropErr := DropDbWithError(dialect, connStr, options)
+		if dropErr != nil {
+			t.Errorf("unable to drop %s test database: %v", dialect, dropErr)
+		}
+	})
+	return db, connStr
+}
+
+// CreateTestSchema
+/* Function that creates throwaway schema with random name (see CreateRandomSchema) for a test in existing database,
+ * server settings are read from the same environment variables as in CreateTestDb, database name is read from
+ * TestDbNameEnvTemplate variable (system database by default). When test (or subtest) finishes schema is dropped with
+ * all its objects and database is closed. If database server is not reachable test is skipped, any other error fails
+ * test. Only Postgres and Mysql are supported
+ * Parameters:
+ *    - t - test state
+ *    - dialect - string that represent using db driver inside gorm (see enum above)
+ *    - options - gorm config, if nil empty config is using
+ * Returns tuple of gorm.DB address of database context object (schema is a default one) and schema name
+ */
+func CreateTestSchema(t testing.TB, dialect SqlDialect, options *g.Config) (*g.DB, string) {
+	t.Helper()
+	if options == nil {
+		options = &g.Config{}
+	}
+	server := getTestDbServer(t, dialect)
+	dbName := getEnv(stringFormatter.Format(TestDbNameEnvTemplate, strings.ToUpper(string(dialect))),
+		getSystemDbName(dialect))
+	connStr := BuildConnectionString(dialect, server.host, server.port, dbName, server.user, server.password,
+		server.sslMode)
+	ctx, cancel := context.WithTimeout(context.Background(), getTestDbTimeout(t))
+	defer cancel()
+	db, schema, err := CreateRandomSchemaContext(ctx, dialect, connStr, options, nil)
+	if err != nil {
+		server.skipIfNotReachable(t, err)
+		t.Fatalf("unable to create %s test schema: %v", dialect, err)
+	}
+	t.Cleanup(func() {
+		dropErr := DropSchema(dialect, db, schema, true)
+		CloseDb(db)
+		if dropErr != nil {
+			t.Errorf("unable to drop %s test schema: %v", dialect, dropErr)
+		}
+	})
+	return db, schema
+}
+
+// CreateTestDbFromTemplate
+/* Function that creates throwaway clone of template (see CreateTemplateDb and CreateRandomDbFromTemplate) for a test,
+ * clone is closed and dropped when test (or subtest) finishes, any error fails test
+ * Parameters:
+ *    - t - test state
+ *    - template - template that was created by CreateTemplateDb
+ *    - options - gorm config, if nil empty config is using
+ * Returns tuple of gorm.DB address of database context object and connStr
+ */
+func CreateTestDbFromTemplate(t testing.TB, template *TemplateDb, options *g.Config) (*g.DB, string) {
+	t.Helper()
+	if options == nil {
+		options = &g.Config{}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), getTestDbTimeout(t))
+	defer cancel()
+	db, connStr, err := CreateRandomDbFromTemplate(ctx, template, options, nil)
+	if err != nil {
+		t.Fatalf("unable to create %s test database from template %s: %v", template.Dialect, template.DbName, err)
+	}
+	t.Cleanup(func() {
+		CloseDb(db)
+		dropErr := DropDbWithError(template.Dialect, connStr, options)
+		if dropErr != nil {
+			t.Errorf("unable to drop %s test database: %v", template.Dialect, dropErr)
+		}
+	})
+	return db, connStr
+}
+
+// getTestDbTimeout
+/* Function that returns test database creation timeout from TestDbTimeoutEnv variable or default timeout
+ */
+func getTestDbTimeout(t testing.TB) time.Duration {
+	t.Helper()
+	timeoutStr := os.Getenv(TestDbTimeoutEnv)
+	if len(timeoutStr) == 0 {
+		return defaultTestDbTimeout
+	}
+	timeout, err := time.ParseDuration(timeoutStr)
+	if err != nil {
+		t.Fatalf("invalid %s value \"%s\": %v", TestDbTimeoutEnv, timeoutStr, err)
+	}
+	return timeout
+}
+
+// getTestDbServer
+/* Function that reads database server settings of dialect from environment variables (see TestDb*EnvTemplate
+ * constants), if they are not set, 127.0.0.1, default dialect port and empty credentials are using
+ */
+func getTestDbServer(t testing.TB, dialect SqlDialect) testDbServer {
+	t.Helper()
+	dialectEnvName := strings.ToUpper(string(dialect))
+	server := testDbServer{dialect: dialect, port: defaultTestDbPorts[dialect]}
+	server.host = getEnv(stringFormatter.Format(TestDbHostEnvTemplate, dialectEnvName), defaultTestDbHost)
+	portStr := os.Getenv(stringFormatter.Format(TestDbPortEnvTemplate, dialectEnvName))
+	if len(portStr) > 0 {
+		var err error
+		server.port, err = strconv.Atoi(portStr)
+		if err != nil {
+			t.Fatalf("invalid %s port \"%s\": %v", dialect, portStr, err)
+		}
+	}
+	server.user = os.Getenv(stringFormatter.Format(TestDbUserEnvTemplate, dialectEnvName))
+	server.password = os.Getenv(stringFormatter.Format(TestDbPasswordEnvTemplate, dialectEnvName))
+	server.sslMode = os.Getenv(stringFormatter.Format(TestDbSslModeEnvTemplate, dialectEnvName))
+	if len(server.sslMode) == 0 && dialect == Postgres {
+		server.sslMode = "disable"
+	}
+	return server
+}
+
+// skipIfNotReachable
+/* Function that skips test if err means that database server is not reachable (connection error or timeout)
+ */
+func (server testDbServer) skipIfNotReachable(t testing.TB, err error) {
+	t.Helper()
+	if IsRetryableDbError(err) || errors.Is(err, context.DeadlineExceeded) {
+		dialectEnvName := strings.ToUpper(string(server.dialect))
+		t.Skipf("%s server is not reachable at %s, test is skipped (set %s and %s to configure it): %v",
+			server.dialect, joinHostPort(server.host, server.port),
+			stringFormatter.Format(TestDbHostEnvTemplate, dialectEnvName),
+			stringFormatter.Format(TestDbPortEnvTemplate, dialectEnvName), err)
+	}
+}
+
+// getEnv
+/* Function that returns environment variable value or default value if variable is not set or empty
+ */
+func getEnv(name string, defaultValue string) string {
+	value := os.Getenv(name)
+	if len(value) == 0 {
+		return defaultValue
+	}
+	return value
+}
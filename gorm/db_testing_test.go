@@ -0,0 +1,40 @@
+package gorm
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+func TestCreateSqliteTestDb(t *testing.T) {
+	var connStr string
+	t.Run("use test database", func(t *testing.T) {
+		connStr = createSqliteTestDbAndCheck(t)
+	})
+	// database is dropped when subtest is finished
+	_, err := os.Stat(connStr)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestCreateTestDbSkipsWhenServerIsNotReachable(t *testing.T) {
+	// nobody listens on port 1
+	t.Setenv("GWUU_TEST_POSTGRES_HOST", "127.0.0.1")
+	t.Setenv("GWUU_TEST_POSTGRES_PORT", "1")
+	var subTest *testing.T
+	t.Run("skipped", func(t *testing.T) {
+		subTest = t
+		CreateTestDb(t, Postgres, nil, nil)
+		t.Error("test should have been skipped")
+	})
+	assert.True(t, subTest.Skipped())
+}
+
+func createSqliteTestDbAndCheck(t *testing.T) string {
+	db, connStr := CreateTestDb(t, Sqlite, nil, nil)
+	assert.NotNil(t, db)
+	prepareDatabase(db)
+	role := Role{Name: "fixture"}
+	assert.NoError(t, db.Create(&role).Error)
+	assert.True(t, CheckDb(Sqlite, connStr, nil))
+	return connStr
+}
@@ -0,0 +1,75 @@
+package gorm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/jackc/pgconn"
+	g "gorm.io/gorm"
+	"time"
+)
+
+const defaultTransactionMaxAttempts = 3
+const defaultTransactionInitialBackoff = 50 * time.Millisecond
+
+// TransactionOptions is an options of WithTransaction
+type TransactionOptions struct {
+	// Isolation is a transaction isolation level (sql.LevelDefault if not set), ignored for nested transactions
+	Isolation sql.IsolationLevel
+	// ReadOnly makes transaction read only, ignored for nested transactions
+	ReadOnly bool
+	// Retry is a retry policy of whole transaction on serialization failures and deadlocks, if it is nil transaction
+	// is repeated up to 3 times with 50ms initial backoff, if IsRetryable is not set serialization failures and
+	// deadlocks are retryable
+	Retry *RetryPolicy
+}
+
+// WithTransaction
+/* Function that calls fn in transaction, commits transaction if fn returned nil and rollbacks it otherwise (or if fn
+ * panics). If db is already a transaction (WithTransaction or db.Transaction is called inside other transaction) fn is
+ * called inside savepoint that is rolled back on error, otherwise if transaction failed with serialization failure or
+ * deadlock (Postgres 40001 / 40P01, Mysql 1213, Mssql 1205) whole transaction with fn is repeated with backoff,
+ * therefore fn should not have side effects outside of transaction
+ * Parameters:
+ *    - ctx - context that bounds transaction and retries
+ *    - db - opened database or transaction
+ *    - options - isolation, read only and retry options (could be nil)
+ *    - fn - function that works with tx
+ * Returns error of fn, begin or commit error of last attempt
+ */
+func WithTransaction(ctx context.Context, db *g.DB, options *TransactionOptions, fn func(tx *g.DB) error) error {
+	db = db.WithContext(ctx)
+	if committer, ok := db.Statement.ConnPool.(g.TxCommitter); ok && committer != nil {
+		// nested transaction, only outer transaction could be repeated
+		return db.Transaction(fn)
+	}
+	policy := RetryPolicy{MaxAttempts: defaultTransactionMaxAttempts, InitialBackoff: defaultTransactionInitialBackoff}
+	var txOptions *sql.TxOptions
+	if options != nil {
+		if options.Retry != nil {
+			policy = *options.Retry
+		}
+		if options.Isolation != sql.LevelDefault || options.ReadOnly {
+			txOptions = &sql.TxOptions{Isolation: options.Isolation, ReadOnly: options.ReadOnly}
+		}
+	}
+	if policy.IsRetryable == nil {
+		policy.IsRetryable = isSerializationFailure
+	}
+	_, err := retry(ctx, &policy, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, db.WithContext(ctx).Transaction(fn, txOptions)
+	})
+	return err
+}
+
+// isSerializationFailure
+/* Function that recognizes serialization failure (Postgres 40001) and deadlock (see IsDeadlock) errors, transaction
+ * that failed with them could succeed if it is repeated
+ */
+func isSerializationFailure(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "40001" {
+		return true
+	}
+	return IsDeadlock(err)
+}
@@ -6,7 +6,6 @@ import (
 )
 
 const defaultPageSize = 25
-//const maximumPageSize = 100
 
 // Paginate
 /* Function for getting data portion (page) by means of GORM
@@ -29,18 +28,20 @@ func Paginate(page int, size int) func(db *gorm.DB) *gorm.DB {
 }
 
 // GetNextTableId
-/* Function for getting next free id (uint) for model that have Model field with ID
+/* Function for getting next free id (uint) for model that have Model field with ID, concurrent callers get same id
  * Parameters:
  *    - db - gorm.DB address of database context object
- *    - table - table name
+ *    - table - table name, it is quoted by gorm dialector
  * Returns MAX(ID) + 1
  */
+//
+// Deprecated: use IdAllocator.
 func GetNextTableId(db *gorm.DB, table string) uint {
 	type Internal struct {
 		Id uint
 	}
 	var maxId Internal
-	getMaxIdQuery := stringFormatter.Format("SELECT MAX(id) As Id FROM {0};", table);
+	getMaxIdQuery := stringFormatter.Format("SELECT MAX(id) As Id FROM {0};", db.Statement.Quote(table));
 	db.Raw(getMaxIdQuery).Scan(&maxId)
 	return maxId.Id + 1
 }
\ No newline at end of file
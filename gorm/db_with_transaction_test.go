@@ -0,0 +1,116 @@
+package gorm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	mssql "github.com/denisenkom/go-mssqldb"
+	driverMysql "github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgconn"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"testing"
+	"time"
+)
+
+func TestIsSerializationFailure(t *testing.T) {
+	assert.True(t, isSerializationFailure(&pgconn.PgError{Code: "40001"}))
+	assert.True(t, isSerializationFailure(&pgconn.PgError{Code: "40P01"}))
+	assert.False(t, isSerializationFailure(&pgconn.PgError{Code: "23505"}))
+	assert.True(t, isSerializationFailure(&driverMysql.MySQLError{Number: 1213}))
+	assert.True(t, isSerializationFailure(mssql.Error{Number: 1205}))
+	assert.False(t, isSerializationFailure(errors.New("deadlock")))
+}
+
+func TestSqliteWithTransaction(t *testing.T) {
+	ctx := context.Background()
+	db, _ := CreateTestDb(t, Sqlite, &gorm.Config{}, nil)
+	prepareDatabase(db)
+	assert.NoError(t, WithTransaction(ctx, db, nil, func(tx *gorm.DB) error {
+		return tx.Create(&Role{Name: "admin"}).Error
+	}))
+	err := WithTransaction(ctx, db, nil, func(tx *gorm.DB) error {
+		createErr := tx.Create(&Role{Name: "guest"}).Error
+		if createErr != nil {
+			return createErr
+		}
+		return errors.New("rollback")
+	})
+	assert.EqualError(t, err, "rollback")
+	assertRowsNumber(t, db, "roles", 1)
+
+	// nested transaction is a savepoint
+	err = WithTransaction(ctx, db, &TransactionOptions{Isolation: sql.LevelSerializable}, func(tx *gorm.DB) error {
+		createErr := tx.Create(&Role{Name: "developer"}).Error
+		if createErr != nil {
+			return createErr
+		}
+		nestedErr := WithTransaction(ctx, tx, nil, func(nestedTx *gorm.DB) error {
+			createErr := nestedTx.Create(&Role{Name: "guest"}).Error
+			if createErr != nil {
+				return createErr
+			}
+			return errors.New("rollback to savepoint")
+		})
+		assert.EqualError(t, nestedErr, "rollback to savepoint")
+		return nil
+	})
+	assert.NoError(t, err)
+	var names []string
+	assert.NoError(t, db.Model(&Role{}).Order("id").Pluck("name", &names).Error)
+	assert.Equal(t, []string{"admin", "developer"}, names)
+}
+
+func TestSqliteWithTransactionRetry(t *testing.T) {
+	ctx := context.Background()
+	db, _ := CreateTestDb(t, Sqlite, &gorm.Config{}, nil)
+	prepareDatabase(db)
+	retry := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+
+	// transaction is repeated on serialization failure and its changes are rolled back
+	attempts := 0
+	err := WithTransaction(ctx, db, &TransactionOptions{Retry: &retry}, func(tx *gorm.DB) error {
+		attempts++
+		createErr := tx.Create(&Role{Name: "admin"}).Error
+		if createErr != nil {
+			return createErr
+		}
+		if attempts < 3 {
+			return &pgconn.PgError{Code: "40001"}
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+	assertRowsNumber(t, db, "roles", 1)
+
+	// attempts are limited
+	attempts = 0
+	err = WithTransaction(ctx, db, &TransactionOptions{Retry: &retry}, func(tx *gorm.DB) error {
+		attempts++
+		return &driverMysql.MySQLError{Number: 1213}
+	})
+	assert.True(t, isSerializationFailure(err))
+	assert.Equal(t, 3, attempts)
+
+	// other errors are not retried
+	attempts = 0
+	err = WithTransaction(ctx, db, nil, func(tx *gorm.DB) error {
+		attempts++
+		return errors.New("permanent")
+	})
+	assert.EqualError(t, err, "permanent")
+	assert.Equal(t, 1, attempts)
+}
+
+func TestPostgresReadOnlyTransaction(t *testing.T) {
+	db, _ := CreateTestDb(t, Postgres, &gorm.Config{}, nil)
+	prepareDatabase(db)
+	options := TransactionOptions{Isolation: sql.LevelSerializable, ReadOnly: true}
+	err := WithTransaction(context.Background(), db, &options, func(tx *gorm.DB) error {
+		return tx.Create(&Role{Name: "admin"}).Error
+	})
+	var pgErr *pgconn.PgError
+	assert.True(t, errors.As(err, &pgErr))
+	assert.Equal(t, "25006", pgErr.Code)
+}